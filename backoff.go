@@ -5,16 +5,33 @@ import (
 	"time"
 )
 
+var (
+	defaultMaxBackoffMs = 60000
+)
+
 // BackOffConfig represents backoff configuration
 type BackOffConfig struct {
 	InitialBackoffMs    int
 	BackoffMultiplier   int
 	BackoffRandomFactor float32
+	// MaxBackoffMs caps every delay. -1 means no cap.
+	MaxBackoffMs int
+	// MaxElapsedMs is how long to retry since the first failure. 0 means forever.
+	MaxElapsedMs int
+	// Policy computes delays. Exponential policy is built from the fields above when nil.
+	Policy RetryPolicy
+	// Clock is used to sleep and measure elapsed time. Default is SystemClock.
+	Clock Clock
+	// RandSeed seeds random source of the backoff. 0 means seeded by current time.
+	RandSeed int64
 }
 
-// Backoff represents exponential backoff
+// Backoff represents backoff state driven by retry policy
 type Backoff struct {
+	attempt           int
 	previousBackoffMs int
+	startedAt         time.Time
+	rnd               *rand.Rand
 	config            *BackOffConfig
 }
 
@@ -34,28 +51,46 @@ func NewBackoff(config *BackOffConfig) *Backoff {
 		config.InitialBackoffMs = 1000
 	}
 
+	if config.MaxBackoffMs == 0 {
+		config.MaxBackoffMs = defaultMaxBackoffMs
+	}
+
+	if config.Clock == nil {
+		config.Clock = SystemClock{}
+	}
+
+	if config.Policy == nil {
+		config.Policy = &ExponentialPolicy{
+			InitialMs:    config.InitialBackoffMs,
+			Multiplier:   config.BackoffMultiplier,
+			RandomFactor: config.BackoffRandomFactor,
+		}
+	}
+
+	seed := config.RandSeed
+
+	if seed == 0 {
+		seed = config.Clock.Now().UnixNano()
+	}
+
 	return &Backoff{
 		config: config,
+		rnd:    rand.New(rand.NewSource(seed)),
 	}
 }
 
 // NextBackoffMs returns next backoff time in milisecond
 func (backoff *Backoff) NextBackoffMs() int {
-	var sleepMs int
-
-	if backoff.previousBackoffMs == 0 {
-		sleepMs = backoff.config.InitialBackoffMs
-	} else {
-		min := int(float32(backoff.previousBackoffMs) * (1 - backoff.config.BackoffRandomFactor))
-		max := int(float32(backoff.previousBackoffMs) * (1 + backoff.config.BackoffRandomFactor))
+	if backoff.attempt == 0 {
+		backoff.startedAt = backoff.config.Clock.Now()
+	}
 
-		baseMs := min
+	backoff.attempt++
 
-		if max > min {
-			baseMs = min + rand.Intn(max-min)
-		}
+	sleepMs := backoff.config.Policy.NextBackoffMs(backoff.attempt, backoff.previousBackoffMs, backoff.rnd)
 
-		sleepMs = baseMs * backoff.config.BackoffMultiplier
+	if backoff.config.MaxBackoffMs > 0 && sleepMs > backoff.config.MaxBackoffMs {
+		sleepMs = backoff.config.MaxBackoffMs
 	}
 
 	backoff.previousBackoffMs = sleepMs
@@ -66,10 +101,20 @@ func (backoff *Backoff) NextBackoffMs() int {
 func (backoff *Backoff) SleepBackoff() {
 	sleepMs := backoff.NextBackoffMs()
 
-	time.Sleep(time.Duration(sleepMs) * time.Millisecond)
+	backoff.config.Clock.Sleep(time.Duration(sleepMs) * time.Millisecond)
 }
 
 // ResetSleepBackoff resets backoff
 func (backoff *Backoff) ResetSleepBackoff() {
+	backoff.attempt = 0
 	backoff.previousBackoffMs = 0
 }
+
+// Exhausted returns true when retrying for longer than MaxElapsedMs
+func (backoff *Backoff) Exhausted() bool {
+	if backoff.config.MaxElapsedMs <= 0 || backoff.attempt == 0 {
+		return false
+	}
+
+	return backoff.config.Clock.Now().Sub(backoff.startedAt) >= time.Duration(backoff.config.MaxElapsedMs)*time.Millisecond
+}
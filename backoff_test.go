@@ -2,6 +2,7 @@ package dbevent
 
 import (
 	"testing"
+	"time"
 )
 
 func TestBackoff_NoRandom(t *testing.T) {
@@ -61,3 +62,107 @@ func TestBackoff_Random(t *testing.T) {
 		t.Errorf("expect backoff to be 1000 but %d", v)
 	}
 }
+
+func TestBackoff_MaxBackoff(t *testing.T) {
+	b := NewBackoff(&BackOffConfig{
+		BackoffRandomFactor: -1,
+		InitialBackoffMs:    1000,
+		MaxBackoffMs:        3000,
+	})
+
+	for _, expected := range []int{1000, 2000, 3000, 3000} {
+		if v := b.NextBackoffMs(); v != expected {
+			t.Errorf("expect backoff to be %d but %d", expected, v)
+		}
+	}
+}
+
+func TestBackoff_Policy(t *testing.T) {
+	b := NewBackoff(&BackOffConfig{
+		Policy: &LinearPolicy{InitialMs: 100, IncrementMs: 100},
+	})
+
+	for _, expected := range []int{100, 200, 300} {
+		if v := b.NextBackoffMs(); v != expected {
+			t.Errorf("expect backoff to be %d but %d", expected, v)
+		}
+	}
+}
+
+func TestBackoff_Exhausted(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+
+	b := NewBackoff(&BackOffConfig{
+		InitialBackoffMs: 1000,
+		MaxElapsedMs:     5000,
+		Clock:            clock,
+	})
+
+	if b.Exhausted() {
+		t.Errorf("expect backoff not to be exhausted before first retry")
+	}
+
+	b.NextBackoffMs()
+	clock.Advance(4 * time.Second)
+	if b.Exhausted() {
+		t.Errorf("expect backoff not to be exhausted")
+	}
+
+	clock.Advance(time.Second)
+	if !b.Exhausted() {
+		t.Errorf("expect backoff to be exhausted")
+	}
+
+	b.ResetSleepBackoff()
+	if b.Exhausted() {
+		t.Errorf("expect backoff not to be exhausted after reset")
+	}
+}
+
+func TestBackoff_SleepFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+
+	b := NewBackoff(&BackOffConfig{
+		BackoffRandomFactor: -1,
+		InitialBackoffMs:    1000,
+		Clock:               clock,
+	})
+
+	done := make(chan bool)
+
+	go func() {
+		b.SleepBackoff()
+		done <- true
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(999 * time.Millisecond)
+
+	select {
+	case <-done:
+		t.Errorf("expect backoff to sleep 1000ms")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Millisecond)
+	<-done
+}
+
+func TestBackoff_RandSeed(t *testing.T) {
+	newBackoff := func() *Backoff {
+		return NewBackoff(&BackOffConfig{
+			BackoffRandomFactor: 0.5,
+			InitialBackoffMs:    1000,
+			RandSeed:            42,
+		})
+	}
+
+	b1 := newBackoff()
+	b2 := newBackoff()
+
+	for i := 0; i < 5; i++ {
+		if v1, v2 := b1.NextBackoffMs(), b2.NextBackoffMs(); v1 != v2 {
+			t.Errorf("expect seeded backoffs to be equal but %d and %d", v1, v2)
+		}
+	}
+}
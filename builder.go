@@ -1,36 +1,122 @@
 package dbevent
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-// builder represents event build data
-type builder struct {
+// EventBuilder represents event build data
+type EventBuilder struct {
 	event *Event
+	clock Clock
+	codec Codec
+	err   error
 }
 
-// NewBuilder returns new builder instance
-func NewBuilder(eventType string) *builder {
-	return &builder{
+// NewBuilder returns new builder of event type with generated event id
+func NewBuilder(eventType string) *EventBuilder {
+	return &EventBuilder{
 		event: &Event{
-			Type: eventType,
+			Type:    eventType,
+			EventID: uuid.NewString(),
 		},
+		clock: SystemClock{},
+		codec: JSONCodec{},
 	}
 }
 
-// Data marshal data and put into the event
-func (builder *builder) Data(data interface{}) *builder {
-	b, _ := json.Marshal(data)
+// Codec sets codec used by Data. Default is JSONCodec.
+func (builder *EventBuilder) Codec(codec Codec) *EventBuilder {
+	builder.codec = codec
+	return builder
+}
+
+// Data marshal data with builder codec and put into the event. Marshal error is returned by Build.
+func (builder *EventBuilder) Data(data interface{}) *EventBuilder {
+	b, err := builder.codec.Marshal(data)
+
+	if err != nil {
+		builder.err = fmt.Errorf("cannot marshal data of %s: %w", builder.event.Type, err)
+		return builder
+	}
 
 	builder.event.Data = b
+	builder.event.ContentType = builder.codec.ContentType()
+	return builder
+}
+
+// Aggregate sets aggregate the event belongs to
+func (builder *EventBuilder) Aggregate(aggregateType string, aggregateID string) *EventBuilder {
+	builder.event.AggregateType = aggregateType
+	builder.event.AggregateID = aggregateID
+	return builder
+}
+
+// Metadata adds metadata attribute
+func (builder *EventBuilder) Metadata(key string, value string) *EventBuilder {
+	if builder.event.Metadata == nil {
+		builder.event.Metadata = make(map[string]string)
+	}
+
+	builder.event.Metadata[key] = value
+	return builder
+}
+
+// EventID overrides generated event id
+func (builder *EventBuilder) EventID(eventID string) *EventBuilder {
+	builder.event.EventID = eventID
+	return builder
+}
+
+// Version sets schema version of data
+func (builder *EventBuilder) Version(version int) *EventBuilder {
+	builder.event.SchemaVersion = version
+	return builder
+}
+
+// At overrides time the event is created at. Default is now of builder clock.
+func (builder *EventBuilder) At(at time.Time) *EventBuilder {
+	builder.event.CreatedAt = &at
+	return builder
+}
+
+// Clock sets clock used to timestamp the event
+func (builder *EventBuilder) Clock(clock Clock) *EventBuilder {
+	builder.clock = clock
 	return builder
 }
 
-// Build returns built event
-func (builder *builder) Build() *Event {
-	now := time.Now()
+// Build returns built event or error when data cannot be marshaled or required fields are missing
+func (builder *EventBuilder) Build() (*Event, error) {
+	if builder.err != nil {
+		return nil, builder.err
+	}
+
+	event := builder.event
+
+	if event.Type == "" {
+		return nil, errors.New("event type is required")
+	}
+
+	if event.EventID == "" {
+		return nil, fmt.Errorf("event id of %s is required", event.Type)
+	}
+
+	if (event.AggregateType == "") != (event.AggregateID == "") {
+		return nil, fmt.Errorf("aggregate of %s requires both type and id", event.Type)
+	}
+
+	if event.SchemaVersion < 0 {
+		return nil, fmt.Errorf("version of %s must not be negative", event.Type)
+	}
+
+	if event.CreatedAt == nil {
+		now := builder.clock.Now()
+		event.CreatedAt = &now
+	}
 
-	builder.event.CreatedAt = &now
-	return builder.event
+	return event, nil
 }
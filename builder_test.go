@@ -2,6 +2,7 @@ package dbevent_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/pongsatt/go-dbevent"
 )
@@ -42,16 +43,20 @@ func TestBuilder(t *testing.T) {
 				build.Data(tt.args.testdata)
 			}
 
-			got := build.Build()
+			got, err := build.Build()
 
-			if got == nil {
-				t.Errorf("Build() != nil")
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
 			}
 
 			if got.Type == "" {
 				t.Errorf("Type must not empty but got %s", got.Type)
 			}
 
+			if got.EventID == "" {
+				t.Errorf("EventID must not empty")
+			}
+
 			if got.CreatedAt == nil {
 				t.Errorf("CreatedAt != nil but got nil")
 			}
@@ -63,3 +68,65 @@ func TestBuilder(t *testing.T) {
 		})
 	}
 }
+
+func TestBuilder_Clock(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	got, _ := dbevent.NewBuilder("testtype").Clock(dbevent.NewFakeClock(now)).Build()
+
+	if got.CreatedAt == nil || !got.CreatedAt.Equal(now) {
+		t.Errorf("CreatedAt must be %s but got %v", now, got.CreatedAt)
+	}
+}
+
+func TestBuilder_Fields(t *testing.T) {
+	at := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	got, err := dbevent.NewBuilder("orderCreated").
+		Aggregate("order", "order1").
+		Metadata("source", "web").
+		EventID("event1").
+		Version(2).
+		At(at).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if got.AggregateType != "order" || got.AggregateID != "order1" {
+		t.Errorf("Aggregate must be order/order1 but got %s/%s", got.AggregateType, got.AggregateID)
+	}
+
+	if got.Metadata["source"] != "web" {
+		t.Errorf("Metadata source must be web but got %v", got.Metadata)
+	}
+
+	if got.EventID != "event1" || got.SchemaVersion != 2 || !got.CreatedAt.Equal(at) {
+		t.Errorf("unexpected event %+v", got)
+	}
+}
+
+func TestBuilder_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		build *dbevent.EventBuilder
+	}{
+		{name: "no type", build: dbevent.NewBuilder("")},
+		{name: "no event id", build: dbevent.NewBuilder("testtype").EventID("")},
+		{name: "aggregate without id", build: dbevent.NewBuilder("testtype").Aggregate("order", "")},
+		{name: "unmarshalable data", build: dbevent.NewBuilder("testtype").Data(make(chan int))},
+		{name: "codec error", build: dbevent.NewBuilder("testtype").Codec(dbevent.ProtobufCodec{}).Data("not proto")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build.Build()
+
+			if err == nil || got != nil {
+				t.Errorf("Build() must fail but got %v", got)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,115 @@
+package dbevent
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock represents time source
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock represents real time clock
+type SystemClock struct{}
+
+// Now returns current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep pauses for duration
+func (SystemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// After waits for duration then sends current time
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// FakeClock represents clock which only moves when advanced. Use it in tests.
+type FakeClock struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeClockWaiter
+}
+
+// fakeClockWaiter represents pending Sleep or After call
+type fakeClockWaiter struct {
+	until time.Time
+	c     chan time.Time
+}
+
+// NewFakeClock creates new fake clock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	clock := &FakeClock{now: now}
+	clock.cond = sync.NewCond(&clock.lock)
+
+	return clock
+}
+
+// Now returns fake current time
+func (clock *FakeClock) Now() time.Time {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	return clock.now
+}
+
+// Sleep blocks until clock is advanced by duration
+func (clock *FakeClock) Sleep(d time.Duration) {
+	<-clock.After(d)
+}
+
+// After sends fake current time once clock is advanced by duration
+func (clock *FakeClock) After(d time.Duration) <-chan time.Time {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	c := make(chan time.Time, 1)
+
+	if d <= 0 {
+		c <- clock.now
+		return c
+	}
+
+	clock.waiters = append(clock.waiters, &fakeClockWaiter{until: clock.now.Add(d), c: c})
+	clock.cond.Broadcast()
+
+	return c
+}
+
+// Advance moves clock forward and wakes up due waiters
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	clock.now = clock.now.Add(d)
+
+	pending := clock.waiters[:0]
+
+	for _, waiter := range clock.waiters {
+		if waiter.until.After(clock.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+
+		waiter.c <- clock.now
+	}
+
+	clock.waiters = pending
+}
+
+// BlockUntil blocks until at least n waiters are pending on the clock
+func (clock *FakeClock) BlockUntil(n int) {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	for len(clock.waiters) < n {
+		clock.cond.Wait()
+	}
+}
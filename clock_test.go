@@ -0,0 +1,60 @@
+package dbevent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock_After(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	c := clock.After(time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+
+	select {
+	case <-c:
+		t.Errorf("expect After not to fire before duration")
+	default:
+	}
+
+	clock.Advance(500 * time.Millisecond)
+
+	select {
+	case now := <-c:
+		assert.Equal(t, start.Add(time.Second), now)
+	default:
+		t.Errorf("expect After to fire after duration")
+	}
+
+	assert.Equal(t, start.Add(time.Second), clock.Now())
+}
+
+func TestFakeClock_Sleep(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+
+	done := make(chan bool)
+
+	go func() {
+		clock.Sleep(time.Minute)
+		done <- true
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+
+	assert.True(t, <-done)
+}
+
+func TestFakeClock_AfterZero(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+
+	select {
+	case <-clock.After(0):
+	default:
+		t.Errorf("expect After to fire immediately for zero duration")
+	}
+}
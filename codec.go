@@ -0,0 +1,138 @@
+package dbevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// ContentTypeJSON is content type of JSON data. Event without content type is JSON.
+	ContentTypeJSON = "application/json"
+	// ContentTypeProtobuf is content type of protobuf data
+	ContentTypeProtobuf = "application/x-protobuf"
+	// ContentTypeMessagePack is content type of MessagePack data
+	ContentTypeMessagePack = "application/msgpack"
+)
+
+var (
+	codecsLock sync.RWMutex
+	codecs     = map[string]Codec{
+		ContentTypeJSON:        JSONCodec{},
+		ContentTypeProtobuf:    ProtobufCodec{},
+		ContentTypeMessagePack: MessagePackCodec{},
+	}
+)
+
+// Codec represents encoding of event data
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// RegisterCodec makes codec available to decode events of its content type
+func RegisterCodec(codec Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+
+	codecs[codec.ContentType()] = codec
+}
+
+// CodecFor returns codec of content type. Empty content type is JSON.
+func CodecFor(contentType string) (Codec, error) {
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+
+	codec, ok := codecs[contentType]
+
+	if !ok {
+		return nil, fmt.Errorf("no codec for content type %s", contentType)
+	}
+
+	return codec, nil
+}
+
+// Decode unmarshals event data into v using codec of event content type
+func (event *Event) Decode(v interface{}) error {
+	codec, err := CodecFor(event.ContentType)
+
+	if err != nil {
+		return err
+	}
+
+	return codec.Unmarshal(event.Data, v)
+}
+
+// JSONCodec represents JSON encoding
+type JSONCodec struct{}
+
+// ContentType returns application/json
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+// Marshal encodes v as JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtobufCodec represents protobuf encoding. Values must be proto.Message.
+type ProtobufCodec struct{}
+
+// ContentType returns application/x-protobuf
+func (ProtobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+// Marshal encodes message as protobuf
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(proto.Message)
+
+	if !ok {
+		return nil, fmt.Errorf("%T is not proto.Message", v)
+	}
+
+	return proto.Marshal(message)
+}
+
+// Unmarshal decodes protobuf into message
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(proto.Message)
+
+	if !ok {
+		return fmt.Errorf("%T is not proto.Message", v)
+	}
+
+	return proto.Unmarshal(data, message)
+}
+
+// MessagePackCodec represents MessagePack encoding
+type MessagePackCodec struct{}
+
+// ContentType returns application/msgpack
+func (MessagePackCodec) ContentType() string {
+	return ContentTypeMessagePack
+}
+
+// Marshal encodes v as MessagePack
+func (MessagePackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal decodes MessagePack into v
+func (MessagePackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
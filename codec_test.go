@@ -0,0 +1,58 @@
+package dbevent
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+type codecData struct {
+	ID   string
+	Size int
+}
+
+func TestCodec_Decode(t *testing.T) {
+	data := &codecData{ID: "test1", Size: 2}
+
+	for _, codec := range []Codec{JSONCodec{}, MessagePackCodec{}} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			event, err := NewBuilder("testtype").Codec(codec).Data(data).Build()
+			assert.NoError(t, err)
+
+			assert.Equal(t, codec.ContentType(), event.ContentType)
+
+			decoded := new(codecData)
+			err = event.Decode(decoded)
+
+			assert.NoError(t, err)
+			assert.Equal(t, data, decoded)
+		})
+	}
+}
+
+func TestCodec_Protobuf(t *testing.T) {
+	event, err := NewBuilder("testtype").Codec(ProtobufCodec{}).Data(&wrappers.StringValue{Value: "test1"}).Build()
+	assert.NoError(t, err)
+
+	assert.Equal(t, ContentTypeProtobuf, event.ContentType)
+
+	decoded := new(wrappers.StringValue)
+	err = event.Decode(decoded)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "test1", decoded.Value)
+
+	_, err = ProtobufCodec{}.Marshal(&codecData{})
+	assert.Error(t, err)
+}
+
+func TestCodecFor(t *testing.T) {
+	codec, err := CodecFor("")
+
+	assert.NoError(t, err)
+	assert.Equal(t, ContentTypeJSON, codec.ContentType())
+
+	_, err = CodecFor("application/unknown")
+	assert.Error(t, err)
+}
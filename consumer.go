@@ -1,32 +1,65 @@
 package dbevent
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
 	defaultWaitChangeTimeoutSec = 20
+	errPreviousEventFailed      = errors.New("previous event of the aggregate failed")
 )
 
 // ConsumerDriver represents event consumer driver
 type ConsumerDriver interface {
 	Fetch(readGroup string, limit int) ([]*Event, error)
 	CommitInTrans(readGroup string, event *Event, handler func() error) error
+	// CommitBatchInTrans commits offsets covering every event in one transaction with handler
+	CommitBatchInTrans(readGroup string, events []*Event, handler func() error) error
 	WaitChange(timeout time.Duration)
 }
 
+// PartitionWatcher is implemented by drivers distributing partitions of a read group among nodes
+type PartitionWatcher interface {
+	WatchPartitions(readGroup string, onAssign func(partitions []int), onRevoke func(partitions []int))
+}
+
 // ConsumerConfig represents consumer configuration
 type ConsumerConfig struct {
 	WaitChangeTimeoutSec int
 	BatchSize            int
+	// BatchMaxWaitMs is how long ConsumeBatch waits for a batch to fill up. 0 means no wait.
+	BatchMaxWaitMs int
+	// Concurrency is number of workers handling fetched events in Consume. 0 or 1 means sequential.
+	// With concurrency, handlers run outside the offset commit transaction.
+	Concurrency int
+	// KeyByAggregate routes events of the same aggregate to the same worker to keep their order
+	KeyByAggregate bool
+	FetchBackoff   *BackOffConfig
+	HandlerBackoff *BackOffConfig
+	// IsRetryable classifies handler error. Event is skipped when error is not retryable
+	// or handler backoff is exhausted. Default retries every error except permanent one.
+	IsRetryable func(err error) bool
+	// Clock is used by consumer and its backoffs. Default is SystemClock.
+	Clock Clock
+	// OnAssign is called when driver assigns partitions of the read group to this consumer
+	OnAssign func(partitions []int)
+	// OnRevoke is called when driver revokes partitions of the read group from this consumer
+	OnRevoke func(partitions []int)
+	// Upcasters upgrade fetched events to the latest version of their type before handlers see them
+	Upcasters *Upcasters
 }
 
 // Backoffer represents backoff algorithm interface
 type Backoffer interface {
 	SleepBackoff()
 	ResetSleepBackoff()
+	Exhausted() bool
 }
 
 // Consumer represents consumer
@@ -36,48 +69,90 @@ type Consumer struct {
 	handlerBackoff Backoffer
 	config         *ConsumerConfig
 	readGroup      string
-	running        bool
-	closeChan      chan bool
+	// running is 1 while consuming. It is read by the consume goroutine and written by Close.
+	running   int32
+	closeChan chan bool
 }
 
-// NewConsumer creates new consumer
+// NewConsumer creates new consumer. It panics when read group contains "@" which scopes read group to tenant.
 func NewConsumer(readGroup string, driver ConsumerDriver, config *ConsumerConfig) *Consumer {
-	if config.WaitChangeTimeoutSec == 0 {
-		config.WaitChangeTimeoutSec = defaultWaitChangeTimeoutSec
+	if err := ValidateReadGroup(readGroup); err != nil {
+		panic(err)
 	}
 
-	if config.BatchSize == 0 {
-		config.BatchSize = 10
+	setConsumerConfigDefaults(config)
+
+	if config.Upcasters != nil {
+		driver = &upcastingConsumerDriver{ConsumerDriver: driver, upcasters: config.Upcasters}
 	}
 
 	return &Consumer{
 		readGroup:      readGroup,
 		driver:         driver,
-		fetchBackoff:   NewBackoff(&BackOffConfig{}),
-		handlerBackoff: NewBackoff(&BackOffConfig{}),
+		fetchBackoff:   NewBackoff(config.FetchBackoff),
+		handlerBackoff: NewBackoff(config.HandlerBackoff),
 		config:         config,
 	}
 }
 
+func setConsumerConfigDefaults(config *ConsumerConfig) {
+	if config.WaitChangeTimeoutSec == 0 {
+		config.WaitChangeTimeoutSec = defaultWaitChangeTimeoutSec
+	}
+
+	if config.BatchSize == 0 {
+		config.BatchSize = 10
+	}
+
+	if config.Clock == nil {
+		config.Clock = SystemClock{}
+	}
+
+	if config.FetchBackoff == nil {
+		config.FetchBackoff = &BackOffConfig{}
+	}
+
+	if config.FetchBackoff.Clock == nil {
+		config.FetchBackoff.Clock = config.Clock
+	}
+
+	if config.HandlerBackoff == nil {
+		config.HandlerBackoff = &BackOffConfig{}
+	}
+
+	if config.HandlerBackoff.Clock == nil {
+		config.HandlerBackoff.Clock = config.Clock
+	}
+
+	if config.IsRetryable == nil {
+		config.IsRetryable = IsRetryable
+	}
+}
+
 // Close consumer
 func (consumer *Consumer) Close() {
-	consumer.running = false
+	atomic.StoreInt32(&consumer.running, 0)
+}
+
+func (consumer *Consumer) isRunning() bool {
+	return atomic.LoadInt32(&consumer.running) == 1
 }
 
 // CloseAndWait closes consumer and wait it to be done
 func (consumer *Consumer) CloseAndWait() {
-	consumer.closeChan = make(chan bool)
 	consumer.Close()
 	<-consumer.closeChan
 }
 
 // Consume subscribes to new event
 func (consumer *Consumer) Consume(onMessage func(event *Event) error) {
-	consumer.running = true
+	atomic.StoreInt32(&consumer.running, 1)
+	consumer.closeChan = make(chan bool, 1)
+	consumer.watchPartitions()
 	driver := consumer.driver
 
 	go func() {
-		for consumer.running {
+		for consumer.isRunning() {
 			events, err := driver.Fetch(consumer.readGroup, consumer.config.BatchSize)
 
 			if err != nil {
@@ -88,11 +163,27 @@ func (consumer *Consumer) Consume(onMessage func(event *Event) error) {
 
 			consumer.fetchBackoff.ResetSleepBackoff()
 
+			if consumer.config.Concurrency > 1 && len(events) > 0 {
+				if err = consumer.handleConcurrently(events, onMessage); err != nil {
+					log.Printf("cannot handle events. error: %s", err)
+					consumer.handlerBackoff.SleepBackoff()
+				} else {
+					consumer.handlerBackoff.ResetSleepBackoff()
+				}
+
+				continue
+			}
+
 			for _, event := range events {
 				err = driver.CommitInTrans(consumer.readGroup, event, func() error {
 					return onMessage(event)
 				})
 
+				if err != nil && consumer.giveUp(err) {
+					log.Printf("giving up event %d. error: %s", event.ID, err)
+					err = consumer.skip(event)
+				}
+
 				if err != nil {
 					consumer.handlerBackoff.SleepBackoff()
 					break
@@ -115,3 +206,201 @@ func (consumer *Consumer) Consume(onMessage func(event *Event) error) {
 	}()
 
 }
+
+// handleConcurrently handles events with worker pool then commits offset of the
+// highest contiguous handled event so an unfinished event is never skipped
+func (consumer *Consumer) handleConcurrently(events []*Event, onMessage func(event *Event) error) error {
+	results := make([]error, len(events))
+	queues := make([]chan int, consumer.config.Concurrency)
+
+	var wg sync.WaitGroup
+
+	for i := range queues {
+		queues[i] = make(chan int, len(events))
+		wg.Add(1)
+
+		go func(queue chan int) {
+			defer wg.Done()
+
+			failedAggregates := make(map[string]bool)
+
+			for index := range queue {
+				event := events[index]
+
+				// keep aggregate order by skipping events after a failed one
+				if consumer.config.KeyByAggregate && failedAggregates[event.AggregateID] {
+					results[index] = errPreviousEventFailed
+					continue
+				}
+
+				if results[index] = onMessage(event); results[index] != nil {
+					failedAggregates[event.AggregateID] = true
+				}
+			}
+		}(queues[i])
+	}
+
+	for i, event := range events {
+		queues[consumer.workerIndex(i, event)] <- i
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+
+	wg.Wait()
+
+	completed := 0
+	for completed < len(events) {
+		if err := results[completed]; err != nil {
+			if !consumer.giveUp(err) {
+				break
+			}
+
+			log.Printf("giving up event %d. error: %s", events[completed].ID, err)
+		}
+
+		completed++
+	}
+
+	if completed > 0 {
+		if err := consumer.skip(events[:completed]...); err != nil {
+			return err
+		}
+	}
+
+	if completed < len(events) {
+		return results[completed]
+	}
+
+	return nil
+}
+
+func (consumer *Consumer) clock() Clock {
+	if consumer.config.Clock == nil {
+		return SystemClock{}
+	}
+
+	return consumer.config.Clock
+}
+
+// giveUp returns true when handler error should not be retried
+func (consumer *Consumer) giveUp(err error) bool {
+	isRetryable := consumer.config.IsRetryable
+
+	if isRetryable == nil {
+		isRetryable = IsRetryable
+	}
+
+	return !isRetryable(err) || consumer.handlerBackoff.Exhausted()
+}
+
+// skip commits offsets of events without handling them
+func (consumer *Consumer) skip(events ...*Event) error {
+	return consumer.driver.CommitBatchInTrans(consumer.readGroup, events, func() error {
+		return nil
+	})
+}
+
+// watchPartitions registers assign and revoke callbacks when driver supports partitions
+func (consumer *Consumer) watchPartitions() {
+	watcher, ok := consumer.driver.(PartitionWatcher)
+
+	if !ok || (consumer.config.OnAssign == nil && consumer.config.OnRevoke == nil) {
+		return
+	}
+
+	watcher.WatchPartitions(consumer.readGroup, consumer.config.OnAssign, consumer.config.OnRevoke)
+}
+
+func (consumer *Consumer) workerIndex(index int, event *Event) int {
+	if !consumer.config.KeyByAggregate {
+		return index % consumer.config.Concurrency
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(event.AggregateID))
+
+	return int(hash.Sum32() % uint32(consumer.config.Concurrency))
+}
+
+// ConsumeBatch subscribes to new events and handles fetched events at once.
+// Offsets of the batch are committed in a single transaction with the handler.
+func (consumer *Consumer) ConsumeBatch(onMessages func(events []*Event) error) {
+	atomic.StoreInt32(&consumer.running, 1)
+	consumer.closeChan = make(chan bool, 1)
+	consumer.watchPartitions()
+	driver := consumer.driver
+
+	go func() {
+		for consumer.isRunning() {
+			events, err := consumer.fetchBatch()
+
+			if err != nil {
+				log.Printf("error while fetching events. error: %s", err)
+				consumer.fetchBackoff.SleepBackoff()
+				continue
+			}
+
+			consumer.fetchBackoff.ResetSleepBackoff()
+
+			if len(events) == 0 {
+				driver.WaitChange(time.Duration(consumer.config.WaitChangeTimeoutSec) * time.Second)
+				continue
+			}
+
+			last := events[len(events)-1]
+
+			err = driver.CommitBatchInTrans(consumer.readGroup, events, func() error {
+				return onMessages(events)
+			})
+
+			if err != nil && consumer.giveUp(err) {
+				log.Printf("giving up events up to %d. error: %s", last.ID, err)
+				err = consumer.skip(events...)
+			}
+
+			if err != nil {
+				log.Printf("cannot commit events up to %d. error: %s", last.ID, err)
+				consumer.handlerBackoff.SleepBackoff()
+				continue
+			}
+
+			consumer.handlerBackoff.ResetSleepBackoff()
+		}
+		consumer.closeChan <- true
+	}()
+}
+
+// fetchBatch fetches events and waits up to BatchMaxWaitMs for the batch to fill up
+func (consumer *Consumer) fetchBatch() ([]*Event, error) {
+	driver := consumer.driver
+
+	events, err := driver.Fetch(consumer.readGroup, consumer.config.BatchSize)
+
+	if err != nil || consumer.config.BatchMaxWaitMs == 0 {
+		return events, err
+	}
+
+	clock := consumer.clock()
+	deadline := clock.Now().Add(time.Duration(consumer.config.BatchMaxWaitMs) * time.Millisecond)
+
+	for consumer.isRunning() && len(events) < consumer.config.BatchSize {
+		remaining := deadline.Sub(clock.Now())
+
+		if remaining <= 0 {
+			break
+		}
+
+		driver.WaitChange(remaining)
+
+		// offset is not committed yet so fetching again returns the same events plus new ones
+		events, err = driver.Fetch(consumer.readGroup, consumer.config.BatchSize)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -112,6 +113,7 @@ func TestConsumer_ConsumeError(t *testing.T) {
 	mockFetchBackoffer.On("ResetSleepBackoff")
 	mockHandlerBackoffer.On("ResetSleepBackoff")
 	mockHandlerBackoffer.On("SleepBackoff")
+	mockHandlerBackoffer.On("Exhausted").Return(false)
 	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
 	mockDriver.On("CommitInTrans", readGroup, mock.Anything, mock.Anything).
 		Return(func(readGroup string, event *Event, handler func() error) error {
@@ -183,3 +185,474 @@ func TestConsumer_FetchError(t *testing.T) {
 
 	assert.Equal(t, false, called)
 }
+
+func TestConsumer_ConsumeBatch(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	events := []*Event{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockHandlerBackoffer.On("ResetSleepBackoff")
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitBatchInTrans", readGroup, events, mock.Anything).
+		Return(func(readGroup string, events []*Event, handler func() error) error {
+			return handler()
+		})
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config:         &ConsumerConfig{BatchSize: 3},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	var gotEvents []*Event
+	onMessages := func(events []*Event) error {
+		gotEvents = events
+		consumer.Close() // run only once
+		wg.Done()
+		return nil
+	}
+
+	consumer.ConsumeBatch(onMessages)
+
+	wg.Wait()
+
+	assert.Equal(t, events, gotEvents)
+	mockDriver.AssertNumberOfCalls(t, "CommitBatchInTrans", 1)
+	mockHandlerBackoffer.AssertNumberOfCalls(t, "ResetSleepBackoff", 1)
+}
+
+func TestConsumer_ConsumeBatchMaxWait(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	events := []*Event{{ID: 1}, {ID: 2}}
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockHandlerBackoffer.On("ResetSleepBackoff")
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events[:1], nil).Once()
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("WaitChange", mock.Anything)
+	mockDriver.On("CommitBatchInTrans", readGroup, events, mock.Anything).
+		Return(func(readGroup string, events []*Event, handler func() error) error {
+			return handler()
+		})
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config:         &ConsumerConfig{BatchSize: 2, BatchMaxWaitMs: 1000},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	var gotEvents []*Event
+	onMessages := func(events []*Event) error {
+		gotEvents = events
+		consumer.Close() // run only once
+		wg.Done()
+		return nil
+	}
+
+	consumer.ConsumeBatch(onMessages)
+
+	wg.Wait()
+
+	assert.Equal(t, events, gotEvents)
+	mockDriver.AssertNumberOfCalls(t, "Fetch", 2)
+	mockDriver.AssertNumberOfCalls(t, "WaitChange", 1)
+}
+
+func TestConsumer_ConsumeBatchError(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	events := []*Event{{ID: 1}, {ID: 2}}
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitBatchInTrans", readGroup, events, mock.Anything).
+		Return(func(readGroup string, events []*Event, handler func() error) error {
+			return handler()
+		})
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config:         &ConsumerConfig{BatchSize: 2},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	mockHandlerBackoffer.On("Exhausted").Return(false)
+	mockHandlerBackoffer.On("SleepBackoff").Once().Run(func(args mock.Arguments) {
+		consumer.Close()
+		wg.Done()
+	})
+
+	consumer.ConsumeBatch(func(events []*Event) error {
+		return errors.New("mock error")
+	})
+
+	wg.Wait()
+
+	mockHandlerBackoffer.AssertNumberOfCalls(t, "SleepBackoff", 1)
+	mockHandlerBackoffer.AssertNumberOfCalls(t, "ResetSleepBackoff", 0)
+}
+
+func TestConsumer_ConsumeConcurrently(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	events := []*Event{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config:         &ConsumerConfig{Concurrency: 2},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockHandlerBackoffer.On("ResetSleepBackoff").Run(func(args mock.Arguments) {
+		consumer.Close() // run only once
+		wg.Done()
+	})
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitBatchInTrans", readGroup, events, mock.Anything).Return(nil)
+
+	var lock sync.Mutex
+	handled := make(map[uint]bool)
+
+	consumer.Consume(func(event *Event) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		handled[event.ID] = true
+		return nil
+	})
+
+	wg.Wait()
+
+	assert.Len(t, handled, 4)
+	mockDriver.AssertNumberOfCalls(t, "CommitBatchInTrans", 1)
+}
+
+func TestConsumer_ConsumeConcurrentlyContiguousCommit(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	events := []*Event{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config:         &ConsumerConfig{Concurrency: 3},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockHandlerBackoffer.On("Exhausted").Return(false)
+	mockHandlerBackoffer.On("SleepBackoff").Once().Run(func(args mock.Arguments) {
+		consumer.Close() // run only once
+		wg.Done()
+	})
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitBatchInTrans", readGroup, events[:1], mock.Anything).Return(nil)
+
+	consumer.Consume(func(event *Event) error {
+		if event.ID == 2 {
+			return errors.New("mock error")
+		}
+		return nil
+	})
+
+	wg.Wait()
+
+	// event 3 is done but offset must stay at event 1 until event 2 succeeds
+	mockDriver.AssertCalled(t, "CommitBatchInTrans", readGroup, events[:1], mock.Anything)
+	mockDriver.AssertNumberOfCalls(t, "CommitBatchInTrans", 1)
+}
+
+func TestConsumer_ConsumeConcurrentlyKeyByAggregate(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	events := []*Event{
+		{ID: 1, AggregateID: "agg1"},
+		{ID: 2, AggregateID: "agg2"},
+		{ID: 3, AggregateID: "agg1"},
+		{ID: 4, AggregateID: "agg1"},
+	}
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config:         &ConsumerConfig{Concurrency: 4, KeyByAggregate: true},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockHandlerBackoffer.On("Exhausted").Return(false)
+	mockHandlerBackoffer.On("SleepBackoff").Once().Run(func(args mock.Arguments) {
+		consumer.Close() // run only once
+		wg.Done()
+	})
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitBatchInTrans", readGroup, events[:2], mock.Anything).Return(nil)
+
+	var lock sync.Mutex
+	var handled []uint
+
+	consumer.Consume(func(event *Event) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		handled = append(handled, event.ID)
+
+		if event.ID == 3 {
+			return errors.New("mock error")
+		}
+		return nil
+	})
+
+	wg.Wait()
+
+	// event 4 must not be handled after event 3 of the same aggregate failed
+	assert.ElementsMatch(t, []uint{1, 2, 3}, handled)
+	mockDriver.AssertNumberOfCalls(t, "CommitBatchInTrans", 1)
+}
+
+func TestConsumer_ConsumePermanentError(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	events := []*Event{{ID: 1}, {ID: 2}}
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config:         &ConsumerConfig{},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockHandlerBackoffer.On("ResetSleepBackoff")
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitInTrans", readGroup, mock.Anything, mock.Anything).
+		Return(func(readGroup string, event *Event, handler func() error) error {
+			return handler()
+		})
+	mockDriver.On("CommitBatchInTrans", readGroup, mock.Anything, mock.Anything).Return(nil)
+
+	var handled []uint
+	consumer.Consume(func(event *Event) error {
+		handled = append(handled, event.ID)
+
+		if event.ID == 1 {
+			return Permanent(errors.New("mock error"))
+		}
+
+		consumer.Close() // run only once
+		wg.Done()
+		return nil
+	})
+
+	wg.Wait()
+
+	// permanent error skips event without retry
+	assert.Equal(t, []uint{1, 2}, handled)
+	mockHandlerBackoffer.AssertNumberOfCalls(t, "SleepBackoff", 0)
+	mockHandlerBackoffer.AssertNumberOfCalls(t, "Exhausted", 0)
+	mockDriver.AssertNumberOfCalls(t, "CommitInTrans", 2)
+	mockDriver.AssertNumberOfCalls(t, "CommitBatchInTrans", 1)
+}
+
+func TestConsumer_ConsumeExhausted(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	events := []*Event{{ID: 1}}
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config:         &ConsumerConfig{},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockHandlerBackoffer.On("Exhausted").Return(true)
+	mockHandlerBackoffer.On("ResetSleepBackoff").Run(func(args mock.Arguments) {
+		consumer.Close() // run only once
+		wg.Done()
+	})
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitInTrans", readGroup, mock.Anything, mock.Anything).
+		Return(func(readGroup string, event *Event, handler func() error) error {
+			return handler()
+		})
+	mockDriver.On("CommitBatchInTrans", readGroup, mock.Anything, mock.Anything).Return(nil)
+
+	consumer.Consume(func(event *Event) error {
+		return errors.New("mock error")
+	})
+
+	wg.Wait()
+
+	mockHandlerBackoffer.AssertNumberOfCalls(t, "SleepBackoff", 0)
+	mockDriver.AssertNumberOfCalls(t, "CommitInTrans", 1)
+	mockDriver.AssertNumberOfCalls(t, "CommitBatchInTrans", 1)
+}
+
+func TestConsumer_FetchBackoffFakeClock(t *testing.T) {
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+
+	clock := NewFakeClock(time.Now())
+	consumer := NewConsumer(readGroup, mockDriver, &ConsumerConfig{
+		Clock:        clock,
+		FetchBackoff: &BackOffConfig{InitialBackoffMs: 1000},
+	})
+
+	events := []*Event{{ID: 1}}
+
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(nil, errors.New("mock error")).Once()
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitInTrans", readGroup, mock.Anything, mock.Anything).
+		Return(func(readGroup string, event *Event, handler func() error) error {
+			return handler()
+		})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	consumer.Consume(func(event *Event) error {
+		consumer.Close() // run only once
+		wg.Done()
+		return nil
+	})
+
+	// consumer sleeps on fake clock after fetch error until advanced
+	clock.BlockUntil(1)
+	mockDriver.AssertNumberOfCalls(t, "Fetch", 1)
+	clock.Advance(time.Second)
+
+	wg.Wait()
+
+	mockDriver.AssertNumberOfCalls(t, "Fetch", 2)
+}
+
+type mockPartitionDriver struct {
+	MockConsumerDriver
+	readGroup string
+	onAssign  func(partitions []int)
+	onRevoke  func(partitions []int)
+}
+
+func (driver *mockPartitionDriver) WatchPartitions(readGroup string, onAssign func(partitions []int), onRevoke func(partitions []int)) {
+	driver.readGroup = readGroup
+	driver.onAssign = onAssign
+	driver.onRevoke = onRevoke
+}
+
+func TestConsumer_WatchPartitions(t *testing.T) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockHandlerBackoffer := &MockBackoffer{}
+	mockDriver := &mockPartitionDriver{}
+
+	readGroup := "testGroup"
+
+	var assigned, revoked []int
+
+	consumer := &Consumer{
+		driver:         mockDriver,
+		fetchBackoff:   mockFetchBackoffer,
+		handlerBackoff: mockHandlerBackoffer,
+		readGroup:      readGroup,
+		config: &ConsumerConfig{
+			OnAssign: func(partitions []int) { assigned = partitions },
+			OnRevoke: func(partitions []int) { revoked = partitions },
+		},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return([]*Event{}, nil)
+	mockDriver.On("WaitChange", mock.Anything).Run(func(args mock.Arguments) {
+		consumer.Close()
+		wg.Done()
+	})
+
+	consumer.Consume(func(event *Event) error {
+		return nil
+	})
+	wg.Wait()
+
+	assert.Equal(t, readGroup, mockDriver.readGroup)
+
+	mockDriver.onAssign([]int{0, 2})
+	mockDriver.onRevoke([]int{1})
+
+	assert.Equal(t, []int{0, 2}, assigned)
+	assert.Equal(t, []int{1}, revoked)
+}
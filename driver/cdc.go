@@ -0,0 +1,315 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+	"github.com/pongsatt/go-dbevent"
+	"github.com/siddontang/go-mysql/canal"
+	binlog "github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+)
+
+// CDCConfig represents change data capture configuration
+type CDCConfig struct {
+	// Name identifies the binlog position persisted for restart. Default is "cdc".
+	Name string
+	// Tables to capture as "schema.table" or "table"
+	Tables []string
+	// Sink receives captured events. Events are written into events table when nil.
+	Sink dbevent.Sink
+}
+
+// CDCData represents data of captured event. Before is nil on insert and After is nil on delete.
+type CDCData struct {
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+}
+
+// MySQLCDC represents change data capture turning row changes of tables into events
+type MySQLCDC struct {
+	canal.DummyEventHandler
+	canal   *canal.Canal
+	driver  *MySQLDriver
+	config  *CDCConfig
+	tables  map[string]bool
+	lock    sync.Mutex
+	pending []*dbevent.Event
+	done    chan error
+}
+
+// NewMySQLCDC creates new instance. Captured events are written using driver.
+func NewMySQLCDC(dbConfig *dbevent.DBConfig, driver *MySQLDriver, config *CDCConfig) (*MySQLCDC, error) {
+	if config.Name == "" {
+		config.Name = "cdc"
+	}
+
+	if len(config.Tables) == 0 {
+		return nil, fmt.Errorf("no table to capture")
+	}
+
+	mysqlConfig, err := mysql.ParseDSN(dbConfig.ToDSN())
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := canal.NewDefaultConfig()
+	cfg.Addr = mysqlConfig.Addr
+	cfg.User = mysqlConfig.User
+	cfg.Password = mysqlConfig.Passwd
+	cfg.Dump.ExecutionPath = "" // do not use mysqldump
+
+	tables := make(map[string]bool)
+
+	for _, table := range config.Tables {
+		if !strings.Contains(table, ".") {
+			table = mysqlConfig.DBName + "." + table
+		}
+
+		tables[table] = true
+		cfg.IncludeTableRegex = append(cfg.IncludeTableRegex, "^"+strings.Replace(table, ".", "\\.", -1)+"$")
+	}
+
+	c, err := canal.NewCanal(cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cdc := &MySQLCDC{
+		canal:  c,
+		driver: driver,
+		config: config,
+		tables: tables,
+		done:   make(chan error, 1),
+	}
+	c.SetEventHandler(cdc)
+
+	return cdc, nil
+}
+
+// Start captures changes from persisted position or current position on first run.
+// Driver must be provisioned.
+func (cdc *MySQLCDC) Start() error {
+	pos, ok, err := cdc.driver.cdcPosition(cdc.config.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		if pos, err = cdc.canal.GetMasterPos(); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		err := cdc.canal.RunFrom(pos)
+
+		if err != nil {
+			log.Printf("change data capture %s stopped. error: %s", cdc.config.Name, err)
+		}
+
+		cdc.done <- err
+	}()
+
+	return nil
+}
+
+// Done returns channel receiving error which stopped capturing
+func (cdc *MySQLCDC) Done() <-chan error {
+	return cdc.done
+}
+
+// Close stops capturing
+func (cdc *MySQLCDC) Close() {
+	cdc.canal.Close()
+}
+
+// OnRow converts row changes into pending events
+func (cdc *MySQLCDC) OnRow(e *canal.RowsEvent) error {
+	if !cdc.tables[e.Table.Schema+"."+e.Table.Name] {
+		return nil
+	}
+
+	createdAt := time.Unix(int64(e.Header.Timestamp), 0)
+	step := 1
+
+	if e.Action == canal.UpdateAction {
+		step = 2
+	}
+
+	cdc.lock.Lock()
+	defer cdc.lock.Unlock()
+
+	for i := 0; i+step <= len(e.Rows); i += step {
+		data := new(CDCData)
+		row := e.Rows[i]
+
+		switch e.Action {
+		case canal.InsertAction:
+			data.After = rowImage(e, row)
+		case canal.DeleteAction:
+			data.Before = rowImage(e, row)
+		case canal.UpdateAction:
+			data.Before = rowImage(e, row)
+			row = e.Rows[i+1]
+			data.After = rowImage(e, row)
+		}
+
+		aggregateID, err := rowKey(e, row)
+
+		if err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(data)
+
+		if err != nil {
+			return err
+		}
+
+		at := createdAt
+		cdc.pending = append(cdc.pending, &dbevent.Event{
+			Type:          fmt.Sprintf("%s.%s", e.Table.Name, e.Action),
+			AggregateType: e.Table.Name,
+			AggregateID:   aggregateID,
+			Data:          dbevent.JSON(b),
+			CreatedAt:     &at,
+		})
+	}
+
+	return nil
+}
+
+// OnXID writes events of committed transaction together with binlog position
+func (cdc *MySQLCDC) OnXID(nextPos binlog.Position) error {
+	return cdc.flush(nextPos)
+}
+
+// OnDDL persists position after schema change
+func (cdc *MySQLCDC) OnDDL(nextPos binlog.Position, _ *replication.QueryEvent) error {
+	return cdc.flush(nextPos)
+}
+
+func (cdc *MySQLCDC) String() string {
+	return "CDC"
+}
+
+// flush publishes pending events and persists position. Events are written with position
+// in one transaction so restart neither loses nor duplicates them. A sink may see
+// events again after restart if position cannot be saved.
+func (cdc *MySQLCDC) flush(pos binlog.Position) error {
+	cdc.lock.Lock()
+	events := cdc.pending
+	cdc.pending = nil
+	cdc.lock.Unlock()
+
+	if cdc.config.Sink != nil {
+		if len(events) > 0 {
+			if err := cdc.config.Sink.Publish(context.Background(), events); err != nil {
+				return err
+			}
+		}
+
+		return cdc.driver.saveCDCPosition(cdc.driver.db, cdc.config.Name, pos)
+	}
+
+	tx, err := cdc.driver.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if len(events) > 0 {
+		if err = cdc.driver.insertEvents(tx, events); err != nil {
+			return err
+		}
+	}
+
+	if err = cdc.driver.saveCDCPosition(tx, cdc.config.Name, pos); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rowImage returns row as column name to value
+func rowImage(e *canal.RowsEvent, row []interface{}) map[string]interface{} {
+	image := make(map[string]interface{}, len(e.Table.Columns))
+
+	for i, column := range e.Table.Columns {
+		if i >= len(row) {
+			break
+		}
+
+		value := row[i]
+
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+
+		image[column.Name] = value
+	}
+
+	return image
+}
+
+// rowKey returns primary key of row. Composite key values are joined by ":".
+func rowKey(e *canal.RowsEvent, row []interface{}) (string, error) {
+	values, err := e.Table.GetPKValues(row)
+
+	if err != nil {
+		return "", err
+	}
+
+	keys := make([]string, len(values))
+
+	for i, value := range values {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+
+		keys[i] = fmt.Sprint(value)
+	}
+
+	return strings.Join(keys, ":"), nil
+}
+
+// cdcPosition returns persisted binlog position of name
+func (db *MySQLDriver) cdcPosition(name string) (binlog.Position, bool, error) {
+	var pos binlog.Position
+
+	query := fmt.Sprintf(`SELECT file, pos FROM %s WHERE name = ?`, db.table("event_cdc_positions"))
+
+	err := db.db.QueryRow(query, name).Scan(&pos.Name, &pos.Pos)
+
+	if err == sql.ErrNoRows {
+		return pos, false, nil
+	}
+
+	if err != nil {
+		return pos, false, err
+	}
+
+	return pos, true, nil
+}
+
+func (db *MySQLDriver) saveCDCPosition(exec execer, name string, pos binlog.Position) error {
+	query := fmt.Sprintf(`INSERT INTO %s (name, file, pos) VALUES (?, ?, ?)
+	ON DUPLICATE KEY UPDATE file = VALUES(file), pos = VALUES(pos)`, db.table("event_cdc_positions"))
+
+	_, err := exec.Exec(query, name, pos.Name, pos.Pos)
+
+	return err
+}
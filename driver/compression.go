@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// CompressionNone stores data as is
+	CompressionNone = ""
+	// CompressionGzip compresses data with gzip
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses data with zstd
+	CompressionZstd = "zstd"
+	// CompressionSnappy compresses data with snappy
+	CompressionSnappy = "snappy"
+)
+
+var (
+	defaultCompressionThreshold = 1024
+	// zstd encoder and decoder are safe for concurrent EncodeAll and DecodeAll
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// compressData compresses data with configured compression when it is at least threshold long.
+// It returns data and compression actually applied.
+func (db *MySQLDriver) compressData(data []byte) ([]byte, string, error) {
+	if db.config.Compression == CompressionNone || len(data) < db.config.CompressionThreshold {
+		return data, CompressionNone, nil
+	}
+
+	compressed, err := compress(db.config.Compression, data)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	// not worth it
+	if len(compressed) >= len(data) {
+		return data, CompressionNone, nil
+	}
+
+	return compressed, db.config.Compression, nil
+}
+
+// validateCompression returns error when compression is unknown
+func validateCompression(compression string) error {
+	switch compression {
+	case CompressionNone, CompressionGzip, CompressionZstd, CompressionSnappy:
+		return nil
+	}
+
+	return fmt.Errorf("unknown compression %s", compression)
+}
+
+func compress(compression string, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(data, nil), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, data), nil
+	}
+
+	return nil, fmt.Errorf("unknown compression %s", compression)
+}
+
+func decompress(compression string, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer r.Close()
+
+		return ioutil.ReadAll(r)
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	case CompressionSnappy:
+		return snappy.Decode(nil, data)
+	}
+
+	return nil, fmt.Errorf("unknown compression %s", compression)
+}
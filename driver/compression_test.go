@@ -0,0 +1,83 @@
+package driver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressDecompress(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"name":"john"}`), 100)
+
+	for _, compression := range []string{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		t.Run(compression, func(t *testing.T) {
+			compressed, err := compress(compression, data)
+
+			assert.NoError(t, err)
+			assert.Less(t, len(compressed), len(data))
+
+			decompressed, err := decompress(compression, compressed)
+
+			assert.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+		})
+	}
+
+	decompressed, err := decompress(CompressionNone, data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+
+	_, err = compress("lz4", data)
+	assert.Error(t, err)
+
+	_, err = decompress("lz4", data)
+	assert.Error(t, err)
+}
+
+func TestCompressData(t *testing.T) {
+	db := &MySQLDriver{config: &MySQLStoreConfig{Compression: CompressionZstd, CompressionThreshold: 64}}
+	data := bytes.Repeat([]byte("a"), 64)
+
+	compressed, compression, err := db.compressData(data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionZstd, compression)
+	assert.Less(t, len(compressed), len(data))
+
+	// below threshold
+	small, compression, err := db.compressData(data[:63])
+
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionNone, compression)
+	assert.Equal(t, data[:63], small)
+
+	// random data does not get smaller
+	random := make([]byte, 256)
+	rand.Read(random)
+
+	stored, compression, err := db.compressData(random)
+
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionNone, compression)
+	assert.Equal(t, random, stored)
+
+	db.config.Compression = CompressionNone
+	stored, compression, err = db.compressData(data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, CompressionNone, compression)
+	assert.Equal(t, data, stored)
+}
+
+func TestValidateCompression(t *testing.T) {
+	assert.NoError(t, validateCompression(CompressionNone))
+	assert.NoError(t, validateCompression(CompressionSnappy))
+	assert.EqualError(t, validateCompression("lz4"), "unknown compression lz4")
+
+	assert.Panics(t, func() {
+		NewMySQLEventDriver(nil, &MySQLStoreConfig{Compression: "lz4"})
+	})
+}
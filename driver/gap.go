@@ -0,0 +1,279 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pongsatt/go-dbevent"
+)
+
+var (
+	defaultGapGraceMs = 1000
+	// read groups not fetched for this long are forgotten and check gaps again from their offset
+	checkedGapRetention = 10 * time.Minute
+)
+
+// GapStats represents statistics of ids missing between fetched events
+type GapStats struct {
+	// Open is number of gaps waiting to be filled
+	Open int
+	// OldestOpen is how long the oldest open gap has been waiting
+	OldestOpen time.Duration
+	// Filled is number of gaps filled by late commits
+	Filled uint64
+	// Expired is number of gaps confirmed as rollback after grace window
+	Expired uint64
+	// HeldBack is number of fetches cut short by an open gap
+	HeldBack uint64
+}
+
+// gapTracker remembers ids missing between fetched events. An id becomes visible out of
+// order when the transaction that allocated it commits after a later one.
+type gapTracker struct {
+	lock      sync.Mutex
+	open      map[uint]time.Time
+	confirmed map[uint]time.Time
+	// checked is id of each read group up to which every id is present or confirmed
+	checked  map[string]*gapWatermark
+	filled   uint64
+	expired  uint64
+	heldBack uint64
+}
+
+type gapWatermark struct {
+	upTo uint
+	at   time.Time
+}
+
+func newGapTracker() *gapTracker {
+	return &gapTracker{
+		open:      make(map[uint]time.Time),
+		confirmed: make(map[uint]time.Time),
+		checked:   make(map[string]*gapWatermark),
+	}
+}
+
+// checkFrom returns id after which gaps of read group must be checked
+func (tracker *gapTracker) checkFrom(name string, offset uint) uint {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	if watermark, ok := tracker.checked[name]; ok && watermark.upTo > offset {
+		return watermark.upTo
+	}
+
+	return offset
+}
+
+// firstPending records missing ids of range (from, to] checked for read group and returns the lowest
+// id still inside grace window. It returns 0 when every gap is filled or confirmed.
+func (tracker *gapTracker) firstPending(name string, from uint, to uint, missing []uint, now time.Time, grace time.Duration) uint {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	isMissing := make(map[uint]bool, len(missing))
+	for _, id := range missing {
+		isMissing[id] = true
+	}
+
+	for id := range tracker.open {
+		if id > from && id <= to && !isMissing[id] {
+			delete(tracker.open, id)
+			tracker.filled++
+		}
+	}
+
+	var pending uint
+
+	for _, id := range missing {
+		if _, ok := tracker.confirmed[id]; ok {
+			continue
+		}
+
+		seenAt, ok := tracker.open[id]
+
+		if !ok {
+			seenAt = now
+			tracker.open[id] = now
+		}
+
+		if now.Sub(seenAt) >= grace {
+			delete(tracker.open, id)
+			tracker.confirmed[id] = now
+			tracker.expired++
+			continue
+		}
+
+		if pending == 0 || id < pending {
+			pending = id
+		}
+	}
+
+	upTo := to
+
+	if pending > 0 {
+		tracker.heldBack++
+		upTo = pending - 1
+	}
+
+	if watermark, ok := tracker.checked[name]; !ok || upTo > watermark.upTo {
+		tracker.checked[name] = &gapWatermark{upTo: upTo, at: now}
+	} else {
+		watermark.at = now
+	}
+
+	tracker.forget(now)
+
+	return pending
+}
+
+// forget drops read groups not fetched lately and confirmed gaps every read group has passed
+func (tracker *gapTracker) forget(now time.Time) {
+	var lowest uint
+	found := false
+
+	for name, watermark := range tracker.checked {
+		if now.Sub(watermark.at) >= checkedGapRetention {
+			delete(tracker.checked, name)
+			continue
+		}
+
+		if !found || watermark.upTo < lowest {
+			lowest = watermark.upTo
+			found = true
+		}
+	}
+
+	for id := range tracker.confirmed {
+		if !found || id <= lowest {
+			delete(tracker.confirmed, id)
+		}
+	}
+}
+
+// retryIn returns time until the earliest open gap leaves grace window. It is 0 without open gap.
+func (tracker *gapTracker) retryIn(now time.Time, grace time.Duration) time.Duration {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	var wait time.Duration
+
+	for _, seenAt := range tracker.open {
+		remaining := seenAt.Add(grace).Sub(now)
+
+		if remaining > 0 && (wait == 0 || remaining < wait) {
+			wait = remaining
+		}
+	}
+
+	return wait
+}
+
+func (tracker *gapTracker) stats(now time.Time) GapStats {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	stats := GapStats{
+		Open:     len(tracker.open),
+		Filled:   tracker.filled,
+		Expired:  tracker.expired,
+		HeldBack: tracker.heldBack,
+	}
+
+	for _, seenAt := range tracker.open {
+		if age := now.Sub(seenAt); age > stats.OldestOpen {
+			stats.OldestOpen = age
+		}
+	}
+
+	return stats
+}
+
+// GapStats returns statistics of gaps seen while fetching
+func (db *MySQLDriver) GapStats() GapStats {
+	return db.gaps.stats(db.config.Clock.Now())
+}
+
+// holdBackGaps cuts events before the first id missing since offset, unless the gap is
+// older than grace window and so considered a rollback. Ids already checked for read group are skipped.
+func (db *MySQLDriver) holdBackGaps(name string, offset uint, events []*dbevent.Event) ([]*dbevent.Event, error) {
+	last := events[len(events)-1].ID
+	offset = db.gaps.checkFrom(name, offset)
+
+	if offset >= last {
+		return events, nil
+	}
+
+	var count uint
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE id > ? AND id <= ?`, db.table("events"))
+
+	if err := db.db.QueryRow(query, offset, last).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	var missing []uint
+
+	if count < last-offset {
+		var err error
+
+		if missing, err = db.missingIDs(offset, last); err != nil {
+			return nil, err
+		}
+	}
+
+	grace := time.Duration(db.config.GapGraceMs) * time.Millisecond
+	pending := db.gaps.firstPending(name, offset, last, missing, db.config.Clock.Now(), grace)
+
+	if pending == 0 {
+		return events, nil
+	}
+
+	held := events[:0]
+
+	for _, event := range events {
+		if event.ID > pending {
+			break
+		}
+
+		held = append(held, event)
+	}
+
+	return held, nil
+}
+
+// missingIDs returns ids of range (from, to] absent from events table
+func (db *MySQLDriver) missingIDs(from uint, to uint) ([]uint, error) {
+	query := fmt.Sprintf(`SELECT id FROM %s WHERE id > ? AND id <= ? ORDER BY id`, db.table("events"))
+
+	rows, err := db.db.Query(query, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	missing := make([]uint, 0)
+	next := from + 1
+
+	for rows.Next() {
+		var id uint
+
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		for ; next < id; next++ {
+			missing = append(missing, next)
+		}
+
+		next = id + 1
+	}
+
+	for ; next <= to; next++ {
+		missing = append(missing, next)
+	}
+
+	return missing, rows.Err()
+}
@@ -0,0 +1,69 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGapTracker_FirstPending(t *testing.T) {
+	tracker := newGapTracker()
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	grace := time.Second
+
+	// id 3 and 5 are missing from (0, 6]
+	assert.Equal(t, uint(3), tracker.firstPending("group1", 0, 6, []uint{3, 5}, now, grace))
+	assert.Equal(t, uint(2), tracker.checkFrom("group1", 0))
+	assert.Equal(t, 500*time.Millisecond, tracker.retryIn(now.Add(500*time.Millisecond), grace))
+
+	// 3 is filled late, 5 is still within grace
+	assert.Equal(t, uint(5), tracker.firstPending("group1", 2, 6, []uint{5}, now.Add(500*time.Millisecond), grace))
+	assert.Equal(t, uint(4), tracker.checkFrom("group1", 0))
+
+	// 5 passes grace window and is confirmed as rollback
+	assert.Equal(t, uint(0), tracker.firstPending("group1", 4, 6, []uint{5}, now.Add(time.Second), grace))
+	assert.Equal(t, uint(6), tracker.checkFrom("group1", 0))
+	assert.Equal(t, time.Duration(0), tracker.retryIn(now.Add(time.Second), grace))
+
+	stats := tracker.stats(now.Add(time.Second))
+	assert.Equal(t, 0, stats.Open)
+	assert.Equal(t, uint64(1), stats.Filled)
+	assert.Equal(t, uint64(1), stats.Expired)
+	assert.Equal(t, uint64(2), stats.HeldBack)
+}
+
+func TestGapTracker_ConfirmedForOtherGroups(t *testing.T) {
+	tracker := newGapTracker()
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	grace := time.Second
+
+	tracker.firstPending("group1", 0, 2, nil, now, grace)
+	tracker.firstPending("group2", 0, 6, []uint{5}, now, grace)
+	tracker.firstPending("group2", 0, 6, []uint{5}, now.Add(time.Second), grace)
+
+	// group1 still behind the rollback so it stays confirmed for it, even long after
+	later := now.Add(time.Hour)
+	assert.Equal(t, uint(2), tracker.checkFrom("group1", 0))
+	assert.Equal(t, uint(0), tracker.firstPending("group1", 2, 6, []uint{5}, now.Add(9*time.Minute), grace))
+	assert.Equal(t, uint(0), tracker.firstPending("group1", 6, 8, nil, later, grace))
+
+	// every group passed it
+	assert.Empty(t, tracker.confirmed)
+}
+
+func TestGapTracker_CheckFromOffset(t *testing.T) {
+	tracker := newGapTracker()
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, uint(10), tracker.checkFrom("group1", 10))
+
+	tracker.firstPending("group1", 10, 20, nil, now, time.Second)
+
+	assert.Equal(t, uint(20), tracker.checkFrom("group1", 10))
+	assert.Equal(t, uint(25), tracker.checkFrom("group1", 25))
+
+	// idle read group checks from its offset again
+	tracker.firstPending("group2", 0, 1, nil, now.Add(checkedGapRetention), time.Second)
+	assert.Equal(t, uint(10), tracker.checkFrom("group1", 10))
+}
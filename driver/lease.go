@@ -0,0 +1,254 @@
+package driver
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrLeaseLost is returned when lease was taken over by another node
+var ErrLeaseLost = errors.New("lease lost")
+
+// Lease represents ownership of a read group lock. Token increases every time
+// the lock changes hand and is used to fence commits of a previous owner.
+type Lease struct {
+	Name      string
+	Token     uint64
+	ExpiresAt time.Time
+}
+
+// AcquireLease acquires lease of name. It returns nil when the lease is held by another node.
+func (db *MySQLDriver) AcquireLease(name string) (*Lease, error) {
+	tx, err := db.db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`INSERT IGNORE INTO %s (name, lock_by, last_seen, token) VALUES (?, '', now(), 0)`, db.table("event_locks"))
+
+	_, err = tx.Exec(query, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var lockBy string
+	var token uint64
+	var expired bool
+
+	query = fmt.Sprintf(`SELECT lock_by, token, last_seen < now() - interval ? second FROM %s WHERE name = ? FOR UPDATE`, db.table("event_locks"))
+
+	err = tx.QueryRow(query, db.config.LockTimeoutSec, name).Scan(&lockBy, &token, &expired)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case lockBy == db.config.NodeID && !expired:
+		query = fmt.Sprintf(`UPDATE %s SET last_seen = now() WHERE name = ?`, db.table("event_locks"))
+		_, err = tx.Exec(query, name)
+	case lockBy == "" || expired:
+		token++
+		query = fmt.Sprintf(`UPDATE %s SET lock_by = ?, token = ?, last_seen = now() WHERE name = ?`, db.table("event_locks"))
+		_, err = tx.Exec(query, db.config.NodeID, token, name)
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &Lease{
+		Name:      name,
+		Token:     token,
+		ExpiresAt: db.leaseExpiry(),
+	}, nil
+}
+
+// RenewLease extends lease. It returns ErrLeaseLost when the lease is owned by another node.
+func (db *MySQLDriver) RenewLease(lease *Lease) error {
+	tx, err := db.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if err = db.checkLease(tx, lease); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET last_seen = now() WHERE name = ?`, db.table("event_locks"))
+
+	_, err = tx.Exec(query, lease.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	db.leaseLock.Lock()
+	lease.ExpiresAt = db.leaseExpiry()
+	db.leaseLock.Unlock()
+
+	return nil
+}
+
+// ReleaseLease gives up lease so another node can take it immediately
+func (db *MySQLDriver) ReleaseLease(lease *Lease) error {
+	query := fmt.Sprintf(`UPDATE %s SET lock_by = '' WHERE name = ? AND lock_by = ? AND token = ?`, db.table("event_locks"))
+
+	_, err := db.db.Exec(query, lease.Name, db.config.NodeID, lease.Token)
+
+	return err
+}
+
+// checkLease verifies lease token inside transaction and locks the row until commit
+func (db *MySQLDriver) checkLease(tx *sql.Tx, lease *Lease) error {
+	var lockBy string
+	var token uint64
+
+	query := fmt.Sprintf(`SELECT lock_by, token FROM %s WHERE name = ? FOR UPDATE`, db.table("event_locks"))
+
+	err := tx.QueryRow(query, lease.Name).Scan(&lockBy, &token)
+
+	if err == sql.ErrNoRows {
+		return ErrLeaseLost
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if lockBy != db.config.NodeID || token != lease.Token {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (db *MySQLDriver) leaseExpiry() time.Time {
+	return db.config.Clock.Now().Add(time.Duration(db.config.LockTimeoutSec) * time.Second)
+}
+
+// holdLease returns held lease of name, acquiring it if needed
+func (db *MySQLDriver) holdLease(name string) (*Lease, error) {
+	db.leaseLock.Lock()
+	lease := db.leases[name]
+	valid := lease != nil && db.config.Clock.Now().Before(lease.ExpiresAt)
+	db.leaseLock.Unlock()
+
+	if valid {
+		return lease, nil
+	}
+
+	lease, err := db.AcquireLease(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	db.leaseLock.Lock()
+	defer db.leaseLock.Unlock()
+
+	if lease == nil {
+		delete(db.leases, name)
+		return nil, nil
+	}
+
+	db.leases[name] = lease
+	return lease, nil
+}
+
+// heldLease returns lease of name held by this driver
+func (db *MySQLDriver) heldLease(name string) *Lease {
+	db.leaseLock.Lock()
+	defer db.leaseLock.Unlock()
+
+	return db.leases[name]
+}
+
+// heartbeat registers node, renews held leases and rebalances partitions in background
+// until driver is closed
+func (db *MySQLDriver) heartbeat() {
+	interval := time.Duration(db.config.HeartbeatIntervalSec) * time.Second
+
+	for {
+		select {
+		case <-db.closeChan:
+			return
+		case <-db.config.Clock.After(interval):
+			if err := db.registerNode(); err != nil {
+				log.Printf("cannot register node %s. error: %s", db.config.NodeID, err)
+			}
+
+			db.renewLeases()
+
+			if db.config.Partitions > 1 {
+				db.rebalanceGroups()
+			}
+		}
+	}
+}
+
+func (db *MySQLDriver) renewLeases() {
+	db.leaseLock.Lock()
+	leases := make([]*Lease, 0, len(db.leases))
+	for _, lease := range db.leases {
+		leases = append(leases, lease)
+	}
+	db.leaseLock.Unlock()
+
+	for _, lease := range leases {
+		err := db.RenewLease(lease)
+
+		if err == ErrLeaseLost {
+			log.Printf("lease %s lost", lease.Name)
+			db.forgetLease(lease)
+			continue
+		}
+
+		if err != nil {
+			log.Printf("cannot renew lease %s. error: %s", lease.Name, err)
+		}
+	}
+}
+
+// forgetLease stops holding lease unless it was already replaced
+func (db *MySQLDriver) forgetLease(lease *Lease) {
+	db.leaseLock.Lock()
+	defer db.leaseLock.Unlock()
+
+	if db.leases[lease.Name] == lease {
+		delete(db.leases, lease.Name)
+	}
+}
+
+// releaseLeases releases every held lease
+func (db *MySQLDriver) releaseLeases() {
+	db.leaseLock.Lock()
+	defer db.leaseLock.Unlock()
+
+	for name, lease := range db.leases {
+		if err := db.ReleaseLease(lease); err != nil {
+			log.Printf("cannot release lease %s. error: %s", name, err)
+		}
+
+		delete(db.leases, name)
+	}
+}
@@ -0,0 +1,406 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/pongsatt/go-dbevent"
+)
+
+var (
+	migrationLockTimeoutSec = 60
+)
+
+// migration represents schema change applied once in version order. It is unexported
+// since Needed takes the unexported querier.
+type migration struct {
+	Version     int
+	Description string
+	// Statements returns SQL of the change
+	Statements func(db *MySQLDriver) []string
+	// Needed returns false when the change is already present in tables created before versioning.
+	// Nil means always needed.
+	Needed func(db *MySQLDriver, conn querier) (bool, error)
+}
+
+// querier represents sql.DB or sql.Conn
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// migrations returns every migration of MySQL driver in version order
+func migrations() []*migration {
+	return []*migration{
+		{
+			Version:     1,
+			Description: "create event tables",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        id INT AUTO_INCREMENT,
+        type TEXT NOT NULL,
+        aggregate_type TEXT NOT NULL,
+        aggregate_id TEXT NOT NULL,
+        data JSON DEFAULT NULL,
+        created_at DATETIME NOT NULL,
+        PRIMARY KEY (id)
+    )`, db.table("events")),
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        name varchar(128) NOT NULL,
+        lock_by varchar(128) NOT NULL,
+        last_seen timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (name)
+    ) ENGINE=InnoDB`, db.table("event_locks")),
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        name varchar(128),
+        offset INT NOT NULL,
+        PRIMARY KEY (name)
+    )`, db.table("event_offsets")),
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        id INT AUTO_INCREMENT,
+        event_id INT NOT NULL,
+        url TEXT NOT NULL,
+        attempt INT NOT NULL,
+        status_code INT NOT NULL,
+        success BOOLEAN NOT NULL,
+        error TEXT,
+        created_at DATETIME NOT NULL,
+        PRIMARY KEY (id),
+        KEY (event_id)
+    )`, db.table("event_deliveries")),
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        node_id varchar(128) NOT NULL,
+        hostname varchar(255) NOT NULL,
+        pid INT NOT NULL,
+        started_at DATETIME NOT NULL,
+        last_seen timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (node_id)
+    ) ENGINE=InnoDB`, db.table("event_nodes")),
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        name varchar(100) NOT NULL,
+        file varchar(255) NOT NULL,
+        pos INT UNSIGNED NOT NULL,
+        updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        PRIMARY KEY (name)
+    ) ENGINE=InnoDB`, db.table("event_cdc_positions")),
+				}
+			},
+		},
+		{
+			Version:     2,
+			Description: "add fencing token to event locks",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN token BIGINT UNSIGNED NOT NULL DEFAULT 0", db.table("event_locks")),
+				}
+			},
+			Needed: func(db *MySQLDriver, conn querier) (bool, error) {
+				exists, err := db.columnExists(conn, db.table("event_locks"), "token")
+				return !exists, err
+			},
+		},
+		{
+			Version:     3,
+			Description: "widen event ids to BIGINT UNSIGNED",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s MODIFY id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT", db.table("events")),
+					fmt.Sprintf("ALTER TABLE %s MODIFY offset BIGINT UNSIGNED NOT NULL", db.table("event_offsets")),
+					fmt.Sprintf("ALTER TABLE %s MODIFY id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT, MODIFY event_id BIGINT UNSIGNED NOT NULL",
+						db.table("event_deliveries")),
+				}
+			},
+		},
+		{
+			Version:     4,
+			Description: "index events by aggregate and creation time",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD INDEX idx_aggregate (aggregate_type(64), aggregate_id(64)), ADD INDEX idx_created_at (created_at)",
+						db.table("events")),
+				}
+			},
+			Needed: func(db *MySQLDriver, conn querier) (bool, error) {
+				exists, err := db.indexExists(conn, db.table("events"), "idx_aggregate")
+				return !exists, err
+			},
+		},
+		{
+			Version:     5,
+			Description: "add tenant to events",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN tenant_id varchar(128) NOT NULL DEFAULT '', ADD INDEX idx_tenant (tenant_id, id)",
+						db.table("events")),
+				}
+			},
+		},
+		{
+			Version:     6,
+			Description: "add metadata to events",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN metadata JSON DEFAULT NULL", db.table("events")),
+				}
+			},
+		},
+		{
+			Version:     7,
+			Description: "create subject key table",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        subject_id varchar(255) NOT NULL,
+        key_id char(36) NOT NULL,
+        key_data VARBINARY(64) NOT NULL,
+        created_at DATETIME NOT NULL,
+        PRIMARY KEY (subject_id, key_id)
+    ) ENGINE=InnoDB`, db.table("event_subject_keys")),
+				}
+			},
+		},
+		{
+			Version:     8,
+			Description: "store binary event data with content type",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s MODIFY data LONGBLOB DEFAULT NULL, ADD COLUMN content_type varchar(100) NOT NULL DEFAULT '%s'",
+						db.table("events"), dbevent.ContentTypeJSON),
+				}
+			},
+		},
+		{
+			Version:     9,
+			Description: "add compression to events",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN compression varchar(16) NOT NULL DEFAULT ''", db.table("events")),
+				}
+			},
+		},
+		{
+			Version:     10,
+			Description: "create event schema table",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        event_type varchar(255) NOT NULL,
+        version INT NOT NULL,
+        definition LONGTEXT NOT NULL,
+        created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (event_type, version)
+    ) ENGINE=InnoDB`, db.table("event_schemas")),
+				}
+			},
+		},
+		{
+			Version:     11,
+			Description: "add schema_version column to events",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN schema_version INT NOT NULL DEFAULT 1", db.table("events")),
+				}
+			},
+		},
+		{
+			Version:     12,
+			Description: "add event_id column to events",
+			Statements: func(db *MySQLDriver) []string {
+				return []string{
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN event_id varchar(64) NOT NULL DEFAULT ''", db.table("events")),
+				}
+			},
+		},
+	}
+}
+
+// Migrate applies pending migrations. Concurrent nodes wait for each other so every migration runs once.
+func (db *MySQLDriver) Migrate() error {
+	ctx := context.Background()
+	conn, err := db.db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if err = db.lockMigration(ctx, conn); err != nil {
+		return err
+	}
+
+	defer db.unlockMigration(ctx, conn)
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+        version INT NOT NULL,
+        description varchar(255) NOT NULL,
+        applied_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        PRIMARY KEY (version)
+    ) ENGINE=InnoDB`, db.table("event_schema_version"))
+
+	if _, err = conn.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	pending, err := db.pendingMigrations(conn)
+
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		needed := true
+
+		if migration.Needed != nil {
+			if needed, err = migration.Needed(db, conn); err != nil {
+				return err
+			}
+		}
+
+		if needed {
+			for _, statement := range migration.Statements(db) {
+				if _, err = conn.ExecContext(ctx, statement); err != nil {
+					return fmt.Errorf("migration %d failed: %w", migration.Version, err)
+				}
+			}
+		}
+
+		query := fmt.Sprintf(`INSERT INTO %s (version, description) VALUES (?, ?)`, db.table("event_schema_version"))
+
+		if _, err = conn.ExecContext(ctx, query, migration.Version, migration.Description); err != nil {
+			return err
+		}
+
+		log.Printf("applied migration %d: %s", migration.Version, migration.Description)
+	}
+
+	return nil
+}
+
+// MigrateDryRun writes SQL of pending migrations without applying them
+func (db *MySQLDriver) MigrateDryRun(w io.Writer) error {
+	pending, err := db.pendingMigrations(db.db)
+
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		if migration.Needed != nil {
+			needed, err := migration.Needed(db, db.db)
+
+			if err != nil {
+				return err
+			}
+
+			if !needed {
+				fmt.Fprintf(w, "-- migration %d: %s (already present)\n", migration.Version, migration.Description)
+				continue
+			}
+		}
+
+		fmt.Fprintf(w, "-- migration %d: %s\n", migration.Version, migration.Description)
+
+		for _, statement := range migration.Statements(db) {
+			fmt.Fprintf(w, "%s;\n", statement)
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the latest applied migration version. It is 0 when nothing is applied.
+func (db *MySQLDriver) SchemaVersion() (int, error) {
+	return db.schemaVersion(db.db)
+}
+
+func (db *MySQLDriver) schemaVersion(conn querier) (int, error) {
+	exists, err := db.tableExists(conn, db.table("event_schema_version"))
+
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	query := fmt.Sprintf(`SELECT MAX(version) FROM %s`, db.table("event_schema_version"))
+
+	if err = conn.QueryRowContext(context.Background(), query).Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// pendingMigrations returns migrations newer than schema version
+func (db *MySQLDriver) pendingMigrations(conn querier) ([]*migration, error) {
+	version, err := db.schemaVersion(conn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]*migration, 0)
+
+	for _, migration := range migrations() {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+func (db *MySQLDriver) lockMigration(ctx context.Context, conn *sql.Conn) error {
+	var locked sql.NullInt64
+
+	err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, db.table("event_schema_migration"), migrationLockTimeoutSec).Scan(&locked)
+
+	if err != nil {
+		return err
+	}
+
+	if locked.Int64 != 1 {
+		return fmt.Errorf("timeout waiting for migration lock")
+	}
+
+	return nil
+}
+
+func (db *MySQLDriver) unlockMigration(ctx context.Context, conn *sql.Conn) {
+	if _, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, db.table("event_schema_migration")); err != nil {
+		log.Printf("cannot release migration lock. error: %s", err)
+	}
+}
+
+func (db *MySQLDriver) tableExists(conn querier, table string) (bool, error) {
+	query := `SELECT count(*) FROM information_schema.tables
+	WHERE table_schema = DATABASE() AND table_name = ?`
+
+	var count int
+	err := conn.QueryRowContext(context.Background(), query, table).Scan(&count)
+
+	return count > 0, err
+}
+
+func (db *MySQLDriver) columnExists(conn querier, table string, column string) (bool, error) {
+	query := `SELECT count(*) FROM information_schema.columns
+	WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?`
+
+	var count int
+	err := conn.QueryRowContext(context.Background(), query, table, column).Scan(&count)
+
+	return count > 0, err
+}
+
+func (db *MySQLDriver) indexExists(conn querier, table string, index string) (bool, error) {
+	query := `SELECT count(*) FROM information_schema.statistics
+	WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?`
+
+	var count int
+	err := conn.QueryRowContext(context.Background(), query, table, index).Scan(&count)
+
+	return count > 0, err
+}
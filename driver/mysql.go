@@ -2,8 +2,12 @@ package driver
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -11,24 +15,92 @@ import (
 )
 
 var (
-	defaultLockTimeoutSec = 20
+	defaultLockTimeoutSec    = 20
+	defaultPollIntervalMs    = 500
+	defaultMaxPollIntervalMs = 5000
 )
 
+const (
+	// ChangeDetectionBinlog waits for change by reading binlog. It falls back to polling
+	// when binlog cannot be read.
+	ChangeDetectionBinlog = "binlog"
+	// ChangeDetectionPolling waits for change by polling the highest event id
+	ChangeDetectionPolling = "polling"
+	// ChangeDetectionNone waits for the whole timeout
+	ChangeDetectionNone = "none"
+)
+
+// changeListener represents event change detection strategy
+type changeListener interface {
+	WaitChange(timeout time.Duration)
+	Close()
+}
+
 // MySQLStoreConfig represents sql store configuration
 type MySQLStoreConfig struct {
+	// NodeID identifies this process in locks and node registry. Default is hostname-pid-random.
 	NodeID         string
 	LockTimeoutSec int
+	// HeartbeatIntervalSec is how often held leases are renewed. Default is a third of LockTimeoutSec.
+	HeartbeatIntervalSec int
+	// Partitions splits every read group by aggregate id so partitions are consumed by different nodes.
+	// 0 or 1 means no partition. It must not change once a read group has committed offsets.
+	Partitions int
+	// Clock is used to timestamp events and wait for change. Default is dbevent.SystemClock.
+	Clock dbevent.Clock
+	// TablePrefix is prepended to every table name so several stores can share one database
+	TablePrefix string
+	// GapGraceMs is how long fetching stops before an id missing between events, waiting
+	// for its transaction to commit. Default is 1000. -1 disables gap detection.
+	GapGraceMs int
+	// Compression is one of CompressionNone (default), CompressionGzip, CompressionZstd or CompressionSnappy.
+	// Data encrypted by store encryptor barely compresses since driver receives it already encrypted.
+	Compression string
+	// CompressionThreshold is the smallest data size in bytes to compress. Default is 1024.
+	CompressionThreshold int
+	// ChangeDetection is one of ChangeDetectionBinlog (default), ChangeDetectionPolling or ChangeDetectionNone
+	ChangeDetection string
+	// PollIntervalMs is the initial polling interval. Default is 500.
+	PollIntervalMs int
+	// MaxPollIntervalMs is the longest polling interval while idle. Default is 5000.
+	MaxPollIntervalMs int
+	// TablePartitioning is one of TablePartitionNone (default), TablePartitionByTime or TablePartitionByID.
+	// Provision partitions events table by range and partitions are maintained in background.
+	TablePartitioning string
+	// TablePartitionDays is days covered by each partition by time. Default is 1.
+	TablePartitionDays int
+	// TablePartitionSize is ids covered by each partition by id. Default is 1000000.
+	TablePartitionSize int
+	// TablePartitionsAhead is number of empty partitions kept ahead. Default is 3.
+	TablePartitionsAhead int
+	// TablePartitionRetentionDays drops partitions of events older than this many days once every
+	// read group committed them. 0 keeps every partition.
+	TablePartitionRetentionDays int
+	// TablePartitionMaintenanceSec is how often partitions are added and dropped. Default is 3600.
+	TablePartitionMaintenanceSec int
 }
 
 // MySQLDriver represents event database
 type MySQLDriver struct {
-	db     *sql.DB
-	change *MySQLChange
-	config *MySQLStoreConfig
+	db        *sql.DB
+	change    changeListener
+	config    *MySQLStoreConfig
+	leases    map[string]*Lease
+	leaseLock sync.Mutex
+	groups    map[string]*partitionGroup
+	groupLock sync.Mutex
+	gaps      *gapTracker
+	closeChan chan bool
+	closeOnce sync.Once
+	startedAt time.Time
 }
 
 // NewMySQLEventDriver creates new instance
 func NewMySQLEventDriver(dbConfig *dbevent.DBConfig, config *MySQLStoreConfig) *MySQLDriver {
+	if err := validateCompression(config.Compression); err != nil {
+		panic(err)
+	}
+
 	db, err := sql.Open("mysql", fmt.Sprintf("%s?parseTime=true", dbConfig.ToDSN()))
 
 	if err != nil {
@@ -41,164 +113,240 @@ func NewMySQLEventDriver(dbConfig *dbevent.DBConfig, config *MySQLStoreConfig) *
 		panic(err)
 	}
 
+	if config.NodeID == "" {
+		config.NodeID = defaultNodeID()
+	}
+
 	if config.LockTimeoutSec == 0 {
 		config.LockTimeoutSec = defaultLockTimeoutSec
 	}
 
-	change := NewMySQLChange(dbConfig, "events")
+	if config.HeartbeatIntervalSec == 0 {
+		config.HeartbeatIntervalSec = config.LockTimeoutSec / 3
 
-	return &MySQLDriver{
-		db:     db,
-		change: change,
-		config: config,
+		if config.HeartbeatIntervalSec == 0 {
+			config.HeartbeatIntervalSec = 1
+		}
 	}
-}
 
-// WaitChange waits for event change
-func (db *MySQLDriver) WaitChange(timeout time.Duration) {
-	db.change.WaitChange(timeout)
-}
+	if config.Clock == nil {
+		config.Clock = dbevent.SystemClock{}
+	}
 
-// Close all mysql resources
-func (db *MySQLDriver) Close() error {
-	db.change.Close()
+	if config.GapGraceMs == 0 {
+		config.GapGraceMs = defaultGapGraceMs
+	}
 
-	if err := db.db.Close(); err != nil {
-		return err
+	if config.CompressionThreshold == 0 {
+		config.CompressionThreshold = defaultCompressionThreshold
 	}
-	return nil
-}
 
-// Provision prepares event tables
-func (db *MySQLDriver) Provision() error {
-	if err := db.createEventTable(); err != nil {
-		return err
+	if config.ChangeDetection == "" {
+		config.ChangeDetection = ChangeDetectionBinlog
 	}
 
-	if err := db.createEventLockTable(); err != nil {
-		return err
+	if config.PollIntervalMs == 0 {
+		config.PollIntervalMs = defaultPollIntervalMs
 	}
 
-	if err := db.createEventOffsetTable(); err != nil {
-		return err
+	if config.MaxPollIntervalMs == 0 {
+		config.MaxPollIntervalMs = defaultMaxPollIntervalMs
 	}
 
-	return nil
-}
+	if config.TablePartitionDays == 0 {
+		config.TablePartitionDays = defaultTablePartitionDays
+	}
+
+	if config.TablePartitionSize == 0 {
+		config.TablePartitionSize = defaultTablePartitionSize
+	}
 
-func (db *MySQLDriver) createEventTable() error {
-	query := `
-    CREATE TABLE IF NOT EXISTS events (
-        id INT AUTO_INCREMENT,
-        type TEXT NOT NULL,
-        aggregate_type TEXT NOT NULL,
-        aggregate_id TEXT NOT NULL,
-		data JSON DEFAULT NULL,
-        created_at DATETIME NOT NULL,
-        PRIMARY KEY (id)
-    );`
+	if config.TablePartitionsAhead == 0 {
+		config.TablePartitionsAhead = defaultTablePartitionsAhead
+	}
 
-	_, err := db.db.Exec(query)
+	if config.TablePartitionMaintenanceSec == 0 {
+		config.TablePartitionMaintenanceSec = defaultTablePartitionMaintenanceSec
+	}
 
-	if err != nil {
-		return err
+	change := newChangeListener(dbConfig, db, config)
+
+	driver := &MySQLDriver{
+		db:        db,
+		change:    change,
+		config:    config,
+		leases:    make(map[string]*Lease),
+		groups:    make(map[string]*partitionGroup),
+		gaps:      newGapTracker(),
+		closeChan: make(chan bool),
+		startedAt: config.Clock.Now(),
 	}
 
-	return nil
+	go driver.heartbeat()
+
+	if config.TablePartitioning != TablePartitionNone {
+		go driver.maintainTablePartitions()
+	}
+
+	return driver
+}
+
+// table returns name of table with configured prefix
+func (db *MySQLDriver) table(name string) string {
+	return db.config.TablePrefix + name
 }
 
-func (db *MySQLDriver) createEventOffsetTable() error {
-	query := `
-    CREATE TABLE IF NOT EXISTS event_offsets (
-        name varchar(128),
-        offset INT NOT NULL,
-        PRIMARY KEY (name)
-    );`
+// newChangeListener creates change listener selected by config
+func newChangeListener(dbConfig *dbevent.DBConfig, db *sql.DB, config *MySQLStoreConfig) changeListener {
+	polling := NewMySQLPollingChange(db, config.TablePrefix+"events", config.PollIntervalMs, config.MaxPollIntervalMs)
+	polling.clock = config.Clock
+
+	switch config.ChangeDetection {
+	case ChangeDetectionPolling:
+		return polling
+	case ChangeDetectionNone:
+		return &noChange{clock: config.Clock}
+	}
 
-	_, err := db.db.Exec(query)
+	binlog, err := newMySQLChange(dbConfig, config.TablePrefix+"events", config.Clock)
 
 	if err != nil {
-		return err
+		log.Printf("cannot listen to binlog, fall back to polling. error: %s", err)
+		return polling
 	}
 
-	return nil
+	return &fallbackChange{binlog: binlog, polling: polling}
 }
 
-func (db *MySQLDriver) currentOffset(readGroup string) (uint, error) {
-	query := `SELECT offset FROM event_offsets WHERE name = ?`
+// ChangeHealth returns status of binlog listener. It is false when binlog is not used.
+func (db *MySQLDriver) ChangeHealth() (ListenerHealth, bool) {
+	change, ok := db.change.(interface{ Health() ListenerHealth })
 
-	var offset uint
-	err := db.db.QueryRow(query, readGroup).Scan(&offset)
+	if !ok {
+		return ListenerHealth{}, false
+	}
 
-	if err != nil {
-		if err != sql.ErrNoRows {
-			return 0, err
+	return change.Health(), true
+}
+
+// WaitChange waits for event change. It returns once an open gap leaves its grace window
+// since a rolled back id never produces a change.
+func (db *MySQLDriver) WaitChange(timeout time.Duration) {
+	if db.config.GapGraceMs > 0 {
+		grace := time.Duration(db.config.GapGraceMs) * time.Millisecond
+
+		if wait := db.gaps.retryIn(db.config.Clock.Now(), grace); wait > 0 && wait < timeout {
+			timeout = wait
 		}
 	}
 
-	return offset, nil
+	db.change.WaitChange(timeout)
 }
 
-func (db *MySQLDriver) createEventLockTable() error {
-	query := `CREATE TABLE IF NOT EXISTS event_locks ( 
-		name varchar(128) NOT NULL, 
-		lock_by varchar(128) NOT NULL, 
-		last_seen timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, 
-		PRIMARY KEY (name) 
-	  ) ENGINE=InnoDB`
+// Close all mysql resources. Closing again does nothing.
+func (db *MySQLDriver) Close() error {
+	var err error
 
-	_, err := db.db.Exec(query)
+	db.closeOnce.Do(func() {
+		close(db.closeChan)
+		db.releaseLeases()
+		db.deregisterNode()
+		db.change.Close()
 
-	if err != nil {
-		return err
-	}
+		err = db.db.Close()
+	})
 
-	return nil
+	return err
 }
 
-func (db *MySQLDriver) lock(name string, nodeID string) (bool, error) {
-	query := `insert ignore into event_locks ( name, lock_by, last_seen ) 
-	values ( ?, ?, now() ) 
-	on duplicate key 
-	update lock_by = if(last_seen < now() - interval ? second, values(lock_by), lock_by), 
-	last_seen = if(lock_by = values(lock_by), values(last_seen), last_seen);`
-
-	_, err := db.db.Exec(query, name, nodeID, db.config.LockTimeoutSec)
+// Provision prepares event tables
+func (db *MySQLDriver) Provision() error {
+	if err := db.Migrate(); err != nil {
+		return err
+	}
 
-	if err != nil {
-		return false, err
+	if db.config.TablePartitioning != TablePartitionNone {
+		if err := db.provisionTablePartitions(); err != nil {
+			return err
+		}
 	}
 
-	var count int
-	err = db.db.QueryRow("select count(*) from event_locks where name=? and lock_by=?", name, nodeID).Scan(&count)
+	return db.registerNode()
+}
 
-	if err != nil {
-		return false, err
-	}
+func (db *MySQLDriver) currentOffset(readGroup string) (uint, error) {
+	query := fmt.Sprintf(`SELECT offset FROM %s WHERE name = ?`, db.table("event_offsets"))
+
+	var offset uint
+	err := db.db.QueryRow(query, readGroup).Scan(&offset)
 
-	if count > 0 {
-		return true, nil
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
 	}
 
-	return false, err
+	return offset, nil
 }
 
 // Create event into database
 func (db *MySQLDriver) Create(events ...*dbevent.Event) error {
-	query := `INSERT INTO events 
-	(type, aggregate_type, aggregate_id, data, created_at) VALUES `
+	return db.insertEvents(db.db, events)
+}
+
+// execer represents sql.DB or sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func (db *MySQLDriver) insertEvents(exec execer, events []*dbevent.Event) error {
+	query := fmt.Sprintf(`INSERT INTO %s 
+	(event_id, type, aggregate_type, aggregate_id, tenant_id, data, content_type, schema_version, compression, metadata, created_at) VALUES `, db.table("events"))
 
 	var inserts []string
 	var params []interface{}
 	for _, event := range events {
-		inserts = append(inserts, "(?, ?, ?, ?, ?)")
-		params = append(params, event.Type, event.AggregateType, event.AggregateID, event.Data, event.CreatedAt)
+		if event.CreatedAt == nil {
+			now := db.config.Clock.Now()
+			event.CreatedAt = &now
+		}
+
+		metadata, err := metadataValue(event.Metadata)
+
+		if err != nil {
+			return err
+		}
+
+		data, compression, err := db.compressData(event.Data)
+
+		if err != nil {
+			return err
+		}
+
+		inserts = append(inserts, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		params = append(params, event.EventID, event.Type, event.AggregateType, event.AggregateID, event.TenantID,
+			data, contentType(event), schemaVersion(event), compression, metadata, event.CreatedAt)
 	}
 
 	queryVals := strings.Join(inserts, ",")
 	query = query + queryVals
 
-	_, err := db.db.Exec(query, params...)
+	_, err := exec.Exec(query, params...)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RecordDelivery stores webhook delivery attempt
+func (db *MySQLDriver) RecordDelivery(delivery *dbevent.Delivery) error {
+	query := fmt.Sprintf(`INSERT INTO %s 
+	(event_id, url, attempt, status_code, success, error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`, db.table("event_deliveries"))
+
+	_, err := db.db.Exec(query, delivery.EventID, delivery.URL, delivery.Attempt,
+		delivery.StatusCode, delivery.Success, delivery.Error, delivery.CreatedAt)
 
 	if err != nil {
 		return err
@@ -209,49 +357,90 @@ func (db *MySQLDriver) Create(events ...*dbevent.Event) error {
 
 // Fetch events from database
 func (db *MySQLDriver) Fetch(readGroup string, limit int) ([]*dbevent.Event, error) {
-	// lock
-	success, err := db.lock(readGroup, db.config.NodeID)
+	if db.config.Partitions > 1 {
+		return db.fetchPartitions(readGroup, limit)
+	}
+
+	_, tenantID := dbevent.ParseTenantReadGroup(readGroup)
+
+	return db.fetchOffset(readGroup, -1, tenantID, limit)
+}
+
+// fetchOffset fetches events after offset of name while holding its lease.
+// Empty tenant means every tenant.
+func (db *MySQLDriver) fetchOffset(name string, partition int, tenantID string, limit int) ([]*dbevent.Event, error) {
+	// lease
+	lease, err := db.holdLease(name)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if !success {
+	if lease == nil {
 		return nil, nil
 	}
 
 	// current offset
-	offset, err := db.currentOffset(readGroup)
+	offset, err := db.currentOffset(name)
 
 	if err != nil {
 		return nil, err
 	}
 
 	// fetch
-	events, err := db.getEvents(offset, limit)
+	events, err := db.getEvents(offset, limit, partition, tenantID)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if db.config.GapGraceMs > 0 && len(events) > 0 {
+		return db.holdBackGaps(name, offset, events)
+	}
+
 	return events, nil
 }
 
-// CommitEvent as processed
+// CommitInTrans commits event as processed
 func (db *MySQLDriver) CommitInTrans(readGroup string, event *dbevent.Event, handler func() error) error {
+	return db.CommitBatchInTrans(readGroup, []*dbevent.Event{event}, handler)
+}
+
+// CommitBatchInTrans commits events as processed. Each partition offset moves to its highest event.
+func (db *MySQLDriver) CommitBatchInTrans(readGroup string, events []*dbevent.Event, handler func() error) error {
+	offsets := make(map[string]uint)
+
+	for _, event := range events {
+		name := db.offsetName(readGroup, event)
+
+		if event.ID > offsets[name] {
+			offsets[name] = event.ID
+		}
+	}
+
+	// lock rows in the same order on every node
+	names := make([]string, 0, len(offsets))
+	for name := range offsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 	tx, err := db.db.Begin()
 
 	if err != nil {
 		return err
 	}
 
-	query := `INSERT INTO event_offsets (name, offset) VALUES (?, ?)
-	ON DUPLICATE KEY UPDATE offset = ?`
+	query := fmt.Sprintf(`INSERT INTO %s (name, offset) VALUES (?, ?)
+	ON DUPLICATE KEY UPDATE offset = ?`, db.table("event_offsets"))
 
-	_, err = tx.Exec(query, readGroup, event.ID, event.ID)
+	for _, name := range names {
+		_, err = tx.Exec(query, name, offsets[name], offsets[name])
 
-	if err != nil {
-		return err
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 
 	// event handler
@@ -260,13 +449,98 @@ func (db *MySQLDriver) CommitInTrans(readGroup string, event *dbevent.Event, han
 		return err
 	}
 
+	// fencing: commit only while still owning the lease it was fetched with
+	for _, name := range names {
+		lease := db.heldLease(name)
+
+		if lease == nil {
+			tx.Rollback()
+			return ErrLeaseLost
+		}
+
+		if err = db.checkLease(tx, lease); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
-func (db *MySQLDriver) getEvents(offset uint, limit int) ([]*dbevent.Event, error) {
-	query := `SELECT id, type, aggregate_type, aggregate_id, data, created_at FROM events WHERE id > ? LIMIT ?`
+// getEvents returns events after offset. Partition -1 means every partition and empty tenant means every tenant.
+func (db *MySQLDriver) getEvents(offset uint, limit int, partition int, tenantID string) ([]*dbevent.Event, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id > ?`, eventColumns, db.table("events"))
+	params := []interface{}{offset}
+
+	if tenantID != "" {
+		query += ` AND tenant_id = ?`
+		params = append(params, tenantID)
+	}
+
+	if partition >= 0 {
+		query += ` AND CRC32(aggregate_id) % ? = ?`
+		params = append(params, db.config.Partitions, partition)
+	}
+
+	query += ` ORDER BY id LIMIT ?`
+	params = append(params, limit)
+
+	return db.queryEvents(query, params...)
+}
+
+// LoadStream returns events of aggregate in order
+func (db *MySQLDriver) LoadStream(aggregateType string, aggregateID string) ([]*dbevent.Event, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE aggregate_type = ? AND aggregate_id = ? ORDER BY id`,
+		eventColumns, db.table("events"))
+
+	return db.queryEvents(query, aggregateType, aggregateID)
+}
 
-	rows, err := db.db.Query(query, offset, limit)
+// ScanEvents returns events after id regardless of read group
+func (db *MySQLDriver) ScanEvents(afterID uint, limit int) ([]*dbevent.Event, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE id > ? ORDER BY id LIMIT ?`, eventColumns, db.table("events"))
+
+	return db.queryEvents(query, afterID, limit)
+}
+
+// UpdateEvents rewrites data and metadata of stored events in one transaction
+func (db *MySQLDriver) UpdateEvents(events ...*dbevent.Event) error {
+	tx, err := db.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`UPDATE %s SET data = ?, compression = ?, metadata = ? WHERE id = ?`, db.table("events"))
+
+	for _, event := range events {
+		metadata, err := metadataValue(event.Metadata)
+
+		if err != nil {
+			return err
+		}
+
+		data, compression, err := db.compressData(event.Data)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(query, data, compression, metadata, event.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const eventColumns = "id, event_id, type, aggregate_type, aggregate_id, tenant_id, data, content_type, schema_version, compression, metadata, created_at"
+
+// queryEvents returns events selected with eventColumns
+func (db *MySQLDriver) queryEvents(query string, params ...interface{}) ([]*dbevent.Event, error) {
+	rows, err := db.db.Query(query, params...)
 
 	if err != nil {
 		return nil, err
@@ -277,15 +551,59 @@ func (db *MySQLDriver) getEvents(offset uint, limit int) ([]*dbevent.Event, erro
 	events := make([]*dbevent.Event, 0)
 
 	for rows.Next() {
+		var data, metadata []byte
+		var compression string
 		event := new(dbevent.Event)
-		err = rows.Scan(&event.ID, &event.Type, &event.AggregateType, &event.AggregateID, &event.Data, &event.CreatedAt)
+		err = rows.Scan(&event.ID, &event.EventID, &event.Type, &event.AggregateType, &event.AggregateID, &event.TenantID,
+			&data, &event.ContentType, &event.SchemaVersion, &compression, &metadata, &event.CreatedAt)
 
 		if err != nil {
 			return nil, err
 		}
 
+		if data != nil {
+			if data, err = decompress(compression, data); err != nil {
+				return nil, fmt.Errorf("cannot decompress event %d: %w", event.ID, err)
+			}
+
+			event.Data = data
+		}
+
+		if len(metadata) > 0 {
+			if err = json.Unmarshal(metadata, &event.Metadata); err != nil {
+				return nil, err
+			}
+		}
+
 		events = append(events, event)
 	}
 
-	return events, nil
+	return events, rows.Err()
+}
+
+// contentType returns content type of event data. Empty means JSON.
+func contentType(event *dbevent.Event) string {
+	if event.ContentType == "" {
+		return dbevent.ContentTypeJSON
+	}
+
+	return event.ContentType
+}
+
+// schemaVersion returns schema version of event data. 0 means version 1.
+func schemaVersion(event *dbevent.Event) int {
+	if event.SchemaVersion == 0 {
+		return 1
+	}
+
+	return event.SchemaVersion
+}
+
+// metadataValue returns metadata as JSON or nil when empty
+func metadataValue(metadata map[string]string) (interface{}, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(metadata)
 }
@@ -0,0 +1,29 @@
+package driver
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/pongsatt/go-dbevent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMySQLDriver_CloseTwice(t *testing.T) {
+	// nothing listens on port 1 so deregistering fails fast
+	sqlDB, err := sql.Open("mysql", "root@tcp(127.0.0.1:1)/testdb")
+	assert.NoError(t, err)
+
+	clock := dbevent.SystemClock{}
+	db := &MySQLDriver{
+		db:        sqlDB,
+		change:    &noChange{clock: clock},
+		config:    &MySQLStoreConfig{Clock: clock},
+		leases:    make(map[string]*Lease),
+		closeChan: make(chan bool),
+	}
+
+	assert.NoError(t, db.Close())
+	assert.NotPanics(t, func() {
+		assert.NoError(t, db.Close())
+	})
+}
@@ -1,6 +1,9 @@
 package driver
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"sync"
 	"time"
 
@@ -9,65 +12,78 @@ import (
 	"github.com/siddontang/go-mysql/canal"
 )
 
-// MySQLChange represents event change listener
+var (
+	listenersLock     sync.Mutex
+	listeners         = make(map[string]*binlogListener)
+	errListenerClosed = errors.New("binlog listener closed")
+)
+
+// ListenerHealth represents status of binlog listener
+type ListenerHealth struct {
+	// Running is true while connected to binlog
+	Running bool
+	// Err is the last error stopping the listener
+	Err error
+	// Reconnects counts reconnections since the listener was created
+	Reconnects int
+	// LastEventAt is when the last row event was received
+	LastEventAt time.Time
+}
+
+// MySQLChange represents event change listener of a table. Listeners of the same
+// MySQL server share one binlog connection.
 type MySQLChange struct {
-	canal.DummyEventHandler
-	canal          *canal.Canal
-	tableName      string
-	waitLock       sync.Mutex
-	waitChangeChan chan bool
-	runningLock    sync.Mutex
-	running        bool
+	listener *binlogListener
+	// table is schema qualified table name since the binlog carries changes of every schema
+	table     string
+	closeOnce sync.Once
+	clock     dbevent.Clock
 }
 
 // NewMySQLChange creates new instance
 func NewMySQLChange(config *dbevent.DBConfig, tableName string) *MySQLChange {
-	mysqlConfig, err := mysql.ParseDSN(config.ToDSN())
-
-	cfg := canal.NewDefaultConfig()
-	cfg.Addr = mysqlConfig.Addr
-	cfg.User = mysqlConfig.User
-	cfg.Password = mysqlConfig.Passwd
-	cfg.Dump.ExecutionPath = "" // do not use mysqldump
-
-	c, err := canal.NewCanal(cfg)
+	change, err := newMySQLChange(config, tableName, dbevent.SystemClock{})
 
 	if err != nil {
 		panic(err)
 	}
 
-	change := &MySQLChange{canal: c, tableName: tableName}
-	c.SetEventHandler(change)
-
 	return change
 }
 
-// OnRow receives change event
-func (h *MySQLChange) OnRow(e *canal.RowsEvent) error {
-	if e.Table.Name == h.tableName {
-		// fmt.Printf("%s table: %s\n", e.Action, e.Table.Name)
-
-		h.waitLock.Lock()
-		if h.waitChangeChan != nil {
-			h.waitChangeChan <- true
-			h.waitChangeChan = nil
-		}
-		h.waitLock.Unlock()
+// newMySQLChange creates new instance. It fails when binlog is not accessible or not in ROW format.
+func newMySQLChange(config *dbevent.DBConfig, tableName string, clock dbevent.Clock) (*MySQLChange, error) {
+	mysqlConfig, err := mysql.ParseDSN(config.ToDSN())
+
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	listener, err := acquireBinlogListener(mysqlConfig, clock)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &MySQLChange{
+		listener: listener,
+		table:    qualifiedTable(mysqlConfig.DBName, tableName),
+		clock:    clock,
+	}, nil
+}
+
+// qualifiedTable returns table name prefixed with its schema
+func qualifiedTable(schema string, tableName string) string {
+	return schema + "." + tableName
 }
 
 // WaitChange waits for change
 func (h *MySQLChange) WaitChange(timeout time.Duration) {
-	h.Run()
-
-	h.waitLock.Lock()
-	h.waitChangeChan = make(chan bool)
-	h.waitLock.Unlock()
+	changed := h.listener.changed(h.table)
 
 	select {
-	case <-h.waitChangeChan:
-	case <-time.After(timeout):
+	case <-changed:
+	case <-h.clock.After(timeout):
 	}
 }
 
@@ -77,29 +93,299 @@ func (h *MySQLChange) String() string {
 
 // Run starts listening
 func (h *MySQLChange) Run() {
-	h.runningLock.Lock()
-	defer h.runningLock.Unlock()
+	h.start()
+}
+
+// Err returns error which stopped listening
+func (h *MySQLChange) Err() error {
+	return h.Health().Err
+}
+
+// Health returns status of the shared binlog listener
+func (h *MySQLChange) Health() ListenerHealth {
+	return h.listener.health()
+}
+
+// start returns error when binlog listener is not connected
+func (h *MySQLChange) start() error {
+	health := h.listener.health()
+
+	if health.Running {
+		return nil
+	}
 
-	if h.running {
+	if health.Err != nil {
+		return health.Err
+	}
+
+	return fmt.Errorf("binlog listener is not running")
+}
+
+// Close stop listening. Binlog connection is closed when the last listener of the server is closed.
+func (h *MySQLChange) Close() {
+	h.closeOnce.Do(func() {
+		releaseBinlogListener(h.listener)
+	})
+}
+
+// binlogListener represents binlog connection shared by every change listener of a MySQL server
+type binlogListener struct {
+	canal.DummyEventHandler
+	key    string
+	config *canal.Config
+	lock   sync.Mutex
+	canal  *canal.Canal
+	refs   int
+	closed bool
+	// tables are change channels by schema qualified table name
+	tables     map[string]chan struct{}
+	running    bool
+	err        error
+	reconnects int
+	lastEvent  time.Time
+	backoff    *dbevent.Backoff
+	clock      dbevent.Clock
+}
+
+// acquireBinlogListener returns listener of the server, connecting the first time.
+// Clock of the first user timestamps events.
+func acquireBinlogListener(mysqlConfig *mysql.Config, clock dbevent.Clock) (*binlogListener, error) {
+	key := fmt.Sprintf("%s@%s", mysqlConfig.User, mysqlConfig.Addr)
+
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+
+	if listener, ok := listeners[key]; ok {
+		listener.lock.Lock()
+		listener.refs++
+		listener.lock.Unlock()
+
+		return listener, nil
+	}
+
+	cfg := canal.NewDefaultConfig()
+	cfg.Addr = mysqlConfig.Addr
+	cfg.User = mysqlConfig.User
+	cfg.Password = mysqlConfig.Passwd
+	cfg.Dump.ExecutionPath = "" // do not use mysqldump
+
+	listener := &binlogListener{
+		key:     key,
+		config:  cfg,
+		refs:    1,
+		tables:  make(map[string]chan struct{}),
+		backoff: dbevent.NewBackoff(&dbevent.BackOffConfig{MaxBackoffMs: 30000, Clock: clock}),
+		clock:   clock,
+	}
+
+	if err := listener.connect(); err != nil {
+		return nil, err
+	}
+
+	listeners[key] = listener
+
+	go listener.run()
+
+	return listener, nil
+}
+
+// releaseBinlogListener closes listener once it has no more users
+func releaseBinlogListener(listener *binlogListener) {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+
+	listener.lock.Lock()
+	listener.refs--
+	last := listener.refs == 0
+
+	if last {
+		listener.closed = true
+	}
+
+	c := listener.canal
+	listener.lock.Unlock()
+
+	if !last {
 		return
 	}
 
-	h.running = true
+	delete(listeners, listener.key)
+
+	if c != nil {
+		c.Close()
+	}
+}
+
+// connect creates new canal. It fails when binlog is not accessible or not in ROW format,
+// or when listener was closed meanwhile.
+func (l *binlogListener) connect() error {
+	c, err := canal.NewCanal(l.config)
 
-	go func() {
-		pos, err := h.canal.GetMasterPos()
+	if err != nil {
+		l.setErr(err)
+		return err
+	}
 
-		if err != nil {
+	c.SetEventHandler(l)
+
+	l.lock.Lock()
+
+	// released while connecting so nobody would close this canal
+	if l.closed {
+		l.lock.Unlock()
+		c.Close()
+		return errListenerClosed
+	}
+
+	l.canal = c
+	l.lock.Unlock()
+
+	return nil
+}
+
+// run follows binlog until closed, reconnecting with backoff when connection is lost
+func (l *binlogListener) run() {
+	for {
+		l.lock.Lock()
+		c := l.canal
+		l.lock.Unlock()
+
+		if c != nil {
+			l.follow(c)
+		}
+
+		if l.isClosed() {
+			return
+		}
+
+		l.backoff.SleepBackoff()
+
+		if l.isClosed() {
 			return
 		}
 
-		if err = h.canal.RunFrom(pos); err != nil {
-			h.running = false
+		err := l.connect()
+
+		if err == errListenerClosed {
+			return
 		}
-	}()
+
+		if err != nil {
+			log.Printf("cannot reconnect binlog listener %s. error: %s", l.key, err)
+			continue
+		}
+
+		l.lock.Lock()
+		l.reconnects++
+		l.lock.Unlock()
+	}
 }
 
-// Close stop listening
-func (h *MySQLChange) Close() {
-	h.canal.Close()
+// follow reads binlog from current position until connection stops
+func (l *binlogListener) follow(c *canal.Canal) {
+	pos, err := c.GetMasterPos()
+
+	if err != nil {
+		log.Printf("cannot get binlog position %s. error: %s", l.key, err)
+		l.setErr(err)
+		c.Close()
+		return
+	}
+
+	l.lock.Lock()
+	l.running = true
+	l.err = nil
+	l.lock.Unlock()
+
+	l.backoff.ResetSleepBackoff()
+
+	// events written while disconnected were missed so every waiter should fetch again
+	l.wakeAll()
+
+	err = c.RunFrom(pos)
+
+	if err == nil && !l.isClosed() {
+		err = fmt.Errorf("binlog listener stopped")
+	}
+
+	l.setErr(err)
+
+	if err != nil && !l.isClosed() {
+		log.Printf("binlog listener %s stopped. error: %s", l.key, err)
+		c.Close()
+	}
+}
+
+// OnRow receives change event
+func (l *binlogListener) OnRow(e *canal.RowsEvent) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.lastEvent = l.clock.Now()
+
+	table := qualifiedTable(e.Table.Schema, e.Table.Name)
+
+	if changed, ok := l.tables[table]; ok {
+		close(changed)
+		delete(l.tables, table)
+	}
+
+	return nil
+}
+
+func (l *binlogListener) String() string {
+	return "BinlogListener"
+}
+
+// changed returns channel closed on next change of schema qualified table
+func (l *binlogListener) changed(table string) <-chan struct{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	changed, ok := l.tables[table]
+
+	if !ok {
+		changed = make(chan struct{})
+		l.tables[table] = changed
+	}
+
+	return changed
+}
+
+// wakeAll releases every waiter
+func (l *binlogListener) wakeAll() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for table, changed := range l.tables {
+		close(changed)
+		delete(l.tables, table)
+	}
+}
+
+func (l *binlogListener) setErr(err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.running = false
+	l.err = err
+}
+
+func (l *binlogListener) isClosed() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.closed
+}
+
+func (l *binlogListener) health() ListenerHealth {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return ListenerHealth{
+		Running:     l.running,
+		Err:         l.err,
+		Reconnects:  l.reconnects,
+		LastEventAt: l.lastEvent,
+	}
 }
@@ -0,0 +1,51 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pongsatt/go-dbevent"
+	"github.com/siddontang/go-mysql/canal"
+	"github.com/siddontang/go-mysql/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBinlogListener() *binlogListener {
+	return &binlogListener{tables: make(map[string]chan struct{}), clock: dbevent.SystemClock{}}
+}
+
+func isClosed(changed <-chan struct{}) bool {
+	select {
+	case <-changed:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestBinlogListener_OnRowSameTableOtherSchema(t *testing.T) {
+	listener := newTestBinlogListener()
+
+	changed1 := listener.changed(qualifiedTable("db1", "events"))
+	changed2 := listener.changed(qualifiedTable("db2", "events"))
+
+	err := listener.OnRow(&canal.RowsEvent{Table: &schema.Table{Schema: "db2", Name: "events"}})
+
+	assert.NoError(t, err)
+	assert.False(t, isClosed(changed1))
+	assert.True(t, isClosed(changed2))
+
+	listener.OnRow(&canal.RowsEvent{Table: &schema.Table{Schema: "db1", Name: "events"}})
+
+	assert.True(t, isClosed(changed1))
+}
+
+func TestBinlogListener_LastEventAt(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	listener := newTestBinlogListener()
+	listener.clock = dbevent.NewFakeClock(now)
+
+	listener.OnRow(&canal.RowsEvent{Table: &schema.Table{Schema: "db1", Name: "events"}})
+
+	assert.Equal(t, now, listener.health().LastEventAt)
+}
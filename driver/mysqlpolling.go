@@ -0,0 +1,162 @@
+package driver
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pongsatt/go-dbevent"
+)
+
+// MySQLPollingChange represents event change listener polling the highest event id.
+// It only needs SELECT privilege.
+type MySQLPollingChange struct {
+	db          *sql.DB
+	tableName   string
+	clock       dbevent.Clock
+	minInterval time.Duration
+	maxInterval time.Duration
+	lock        sync.Mutex
+	interval    time.Duration
+	lastID      uint64
+}
+
+// NewMySQLPollingChange creates new instance. Polling interval starts at minIntervalMs,
+// doubles while nothing changes up to maxIntervalMs and resets when a change is seen.
+func NewMySQLPollingChange(db *sql.DB, tableName string, minIntervalMs int, maxIntervalMs int) *MySQLPollingChange {
+	minInterval := time.Duration(minIntervalMs) * time.Millisecond
+
+	return &MySQLPollingChange{
+		db:          db,
+		tableName:   tableName,
+		clock:       dbevent.SystemClock{},
+		minInterval: minInterval,
+		maxInterval: time.Duration(maxIntervalMs) * time.Millisecond,
+		interval:    minInterval,
+	}
+}
+
+// WaitChange waits until the highest event id grows or timeout
+func (h *MySQLPollingChange) WaitChange(timeout time.Duration) {
+	deadline := h.clock.Now().Add(timeout)
+
+	h.lock.Lock()
+	baseline := h.lastID
+	h.lock.Unlock()
+
+	for {
+		maxID, err := h.maxID()
+
+		if err != nil {
+			log.Printf("cannot poll %s. error: %s", h.tableName, err)
+		} else if h.observe(maxID) > baseline {
+			return
+		}
+
+		remaining := deadline.Sub(h.clock.Now())
+
+		if remaining <= 0 {
+			return
+		}
+
+		interval := h.nextInterval()
+
+		if interval > remaining {
+			interval = remaining
+		}
+
+		<-h.clock.After(interval)
+	}
+}
+
+// observe records highest id and resets interval when it grows
+func (h *MySQLPollingChange) observe(maxID uint64) uint64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if maxID > h.lastID {
+		h.lastID = maxID
+		h.interval = h.minInterval
+	}
+
+	return maxID
+}
+
+// nextInterval returns current interval and backs off the next one
+func (h *MySQLPollingChange) nextInterval() time.Duration {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	interval := h.interval
+
+	h.interval *= 2
+
+	if h.interval > h.maxInterval {
+		h.interval = h.maxInterval
+	}
+
+	return interval
+}
+
+func (h *MySQLPollingChange) maxID() (uint64, error) {
+	var maxID sql.NullInt64
+
+	err := h.db.QueryRow(fmt.Sprintf("SELECT MAX(id) FROM %s", h.tableName)).Scan(&maxID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(maxID.Int64), nil
+}
+
+func (h *MySQLPollingChange) String() string {
+	return "PollingChange"
+}
+
+// Close stop listening
+func (h *MySQLPollingChange) Close() {
+}
+
+// noChange waits for the whole timeout without detecting change
+type noChange struct {
+	clock dbevent.Clock
+}
+
+// WaitChange waits for timeout
+func (h *noChange) WaitChange(timeout time.Duration) {
+	<-h.clock.After(timeout)
+}
+
+// Close does nothing
+func (h *noChange) Close() {
+}
+
+// fallbackChange uses binlog listener and polls while binlog listener is disconnected
+type fallbackChange struct {
+	binlog  *MySQLChange
+	polling *MySQLPollingChange
+}
+
+// WaitChange waits for change using binlog or polling
+func (h *fallbackChange) WaitChange(timeout time.Duration) {
+	if err := h.binlog.start(); err != nil {
+		h.polling.WaitChange(timeout)
+		return
+	}
+
+	h.binlog.WaitChange(timeout)
+}
+
+// Health returns status of binlog listener
+func (h *fallbackChange) Health() ListenerHealth {
+	return h.binlog.Health()
+}
+
+// Close stop listening
+func (h *fallbackChange) Close() {
+	h.binlog.Close()
+	h.polling.Close()
+}
@@ -0,0 +1,160 @@
+package driver
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NodeInfo represents node registered in event_nodes
+type NodeInfo struct {
+	NodeID    string
+	Hostname  string
+	PID       int
+	StartedAt time.Time
+	LastSeen  time.Time
+	// Alive is false when node has not sent heartbeat within lock timeout
+	Alive bool
+	// ReadGroups owned by the node
+	ReadGroups []string
+	// Partitions owned by the node by read group
+	Partitions map[string][]int
+}
+
+// defaultNodeID returns node id unique to this process
+func defaultNodeID() string {
+	hostname, err := os.Hostname()
+
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString()[:8])
+}
+
+// registerNode inserts this node or refreshes its heartbeat
+func (db *MySQLDriver) registerNode() error {
+	hostname, _ := os.Hostname()
+
+	query := fmt.Sprintf(`INSERT INTO %s (node_id, hostname, pid, started_at, last_seen)
+	VALUES (?, ?, ?, ?, now())
+	ON DUPLICATE KEY UPDATE last_seen = now()`, db.table("event_nodes"))
+
+	_, err := db.db.Exec(query, db.config.NodeID, hostname, os.Getpid(), db.startedAt)
+
+	return err
+}
+
+// deregisterNode removes this node
+func (db *MySQLDriver) deregisterNode() error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE node_id = ?`, db.table("event_nodes"))
+
+	_, err := db.db.Exec(query, db.config.NodeID)
+
+	return err
+}
+
+// ListNodes returns registered nodes with read groups and partitions they own
+func (db *MySQLDriver) ListNodes() ([]*NodeInfo, error) {
+	query := fmt.Sprintf(`SELECT node_id, hostname, pid, started_at, last_seen, last_seen >= now() - interval ? second
+	FROM %s ORDER BY node_id`, db.table("event_nodes"))
+
+	rows, err := db.db.Query(query, db.config.LockTimeoutSec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	nodes := make([]*NodeInfo, 0)
+	nodeByID := make(map[string]*NodeInfo)
+
+	for rows.Next() {
+		node := new(NodeInfo)
+		err = rows.Scan(&node.NodeID, &node.Hostname, &node.PID, &node.StartedAt, &node.LastSeen, &node.Alive)
+
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+		nodeByID[node.NodeID] = node
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	owners, err := db.leaseOwners()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for name, owner := range owners {
+		node, ok := nodeByID[owner]
+
+		if !ok {
+			continue
+		}
+
+		readGroup, partition := parsePartitionName(name)
+
+		if partition < 0 {
+			node.ReadGroups = append(node.ReadGroups, readGroup)
+			continue
+		}
+
+		if node.Partitions == nil {
+			node.Partitions = make(map[string][]int)
+		}
+
+		if _, ok := node.Partitions[readGroup]; !ok {
+			node.ReadGroups = append(node.ReadGroups, readGroup)
+		}
+
+		node.Partitions[readGroup] = append(node.Partitions[readGroup], partition)
+	}
+
+	for _, node := range nodes {
+		sort.Strings(node.ReadGroups)
+
+		for _, partitions := range node.Partitions {
+			sort.Ints(partitions)
+		}
+	}
+
+	return nodes, nil
+}
+
+// leaseOwners returns owner node of every unexpired lease by lease name
+func (db *MySQLDriver) leaseOwners() (map[string]string, error) {
+	query := fmt.Sprintf(`SELECT name, lock_by FROM %s
+	WHERE lock_by != '' AND last_seen >= now() - interval ? second`, db.table("event_locks"))
+
+	rows, err := db.db.Query(query, db.config.LockTimeoutSec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	owners := make(map[string]string)
+
+	for rows.Next() {
+		var name, owner string
+
+		if err = rows.Scan(&name, &owner); err != nil {
+			return nil, err
+		}
+
+		owners[name] = owner
+	}
+
+	return owners, rows.Err()
+}
@@ -0,0 +1,276 @@
+package driver
+
+import (
+	"fmt"
+	"hash/crc32"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pongsatt/go-dbevent"
+)
+
+// partitionGroup represents partitioned read group consumed by this node
+type partitionGroup struct {
+	lock       sync.Mutex
+	readGroup  string
+	owned      map[int]bool
+	rebalanced bool
+	onAssign   func(partitions []int)
+	onRevoke   func(partitions []int)
+}
+
+// partitionName returns lease and offset name of read group partition
+func partitionName(readGroup string, partition int) string {
+	return fmt.Sprintf("%s#%d", readGroup, partition)
+}
+
+// parsePartitionName splits lease name into read group and partition. Partition is -1 when absent.
+func parsePartitionName(name string) (string, int) {
+	i := strings.LastIndex(name, "#")
+
+	if i < 0 {
+		return name, -1
+	}
+
+	partition, err := strconv.Atoi(name[i+1:])
+
+	if err != nil {
+		return name, -1
+	}
+
+	return name[:i], partition
+}
+
+// partitionOf returns partition of event. It matches CRC32(aggregate_id) % partitions in MySQL.
+func (db *MySQLDriver) partitionOf(event *dbevent.Event) int {
+	return int(crc32.ChecksumIEEE([]byte(event.AggregateID)) % uint32(db.config.Partitions))
+}
+
+// offsetName returns name of the offset and lease covering event
+func (db *MySQLDriver) offsetName(readGroup string, event *dbevent.Event) string {
+	if db.config.Partitions <= 1 {
+		return readGroup
+	}
+
+	return partitionName(readGroup, db.partitionOf(event))
+}
+
+// WatchPartitions registers callbacks notified when partitions of read group are assigned or revoked
+func (db *MySQLDriver) WatchPartitions(readGroup string, onAssign func(partitions []int), onRevoke func(partitions []int)) {
+	group := db.partitionGroup(readGroup)
+
+	group.lock.Lock()
+	defer group.lock.Unlock()
+
+	group.onAssign = onAssign
+	group.onRevoke = onRevoke
+}
+
+func (db *MySQLDriver) partitionGroup(readGroup string) *partitionGroup {
+	db.groupLock.Lock()
+	defer db.groupLock.Unlock()
+
+	group, ok := db.groups[readGroup]
+
+	if !ok {
+		group = &partitionGroup{
+			readGroup: readGroup,
+			owned:     make(map[int]bool),
+		}
+		db.groups[readGroup] = group
+	}
+
+	return group
+}
+
+// fetchPartitions fetches events of partitions assigned to this node ordered by id
+func (db *MySQLDriver) fetchPartitions(readGroup string, limit int) ([]*dbevent.Event, error) {
+	group := db.partitionGroup(readGroup)
+
+	group.lock.Lock()
+	rebalanced := group.rebalanced
+	group.lock.Unlock()
+
+	if !rebalanced {
+		if err := db.rebalance(group); err != nil {
+			return nil, err
+		}
+	}
+
+	_, tenantID := dbevent.ParseTenantReadGroup(readGroup)
+	events := make([]*dbevent.Event, 0)
+
+	for _, partition := range group.partitions() {
+		partitionEvents, err := db.fetchOffset(partitionName(readGroup, partition), partition, tenantID, limit)
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, partitionEvents...)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+// partitions returns owned partitions in order
+func (group *partitionGroup) partitions() []int {
+	group.lock.Lock()
+	defer group.lock.Unlock()
+
+	partitions := make([]int, 0, len(group.owned))
+	for partition := range group.owned {
+		partitions = append(partitions, partition)
+	}
+	sort.Ints(partitions)
+
+	return partitions
+}
+
+// rebalance spreads partitions evenly across alive nodes. Every node computes the same
+// assignment from event_nodes, releases partitions it no longer owns and acquires the new ones.
+// A partition still leased by its previous owner is picked up on a later heartbeat.
+func (db *MySQLDriver) rebalance(group *partitionGroup) error {
+	nodes, err := db.aliveNodeIDs()
+
+	if err != nil {
+		return err
+	}
+
+	assigned := db.assignedPartitions(nodes)
+
+	group.lock.Lock()
+
+	var revoked, acquired []int
+
+	for partition := range group.owned {
+		if assigned[partition] {
+			continue
+		}
+
+		if lease := db.heldLease(partitionName(group.readGroup, partition)); lease != nil {
+			if err := db.ReleaseLease(lease); err != nil {
+				log.Printf("cannot release partition %d of %s. error: %s", partition, group.readGroup, err)
+			}
+
+			db.forgetLease(lease)
+		}
+
+		delete(group.owned, partition)
+		revoked = append(revoked, partition)
+	}
+
+	for partition := 0; partition < db.config.Partitions; partition++ {
+		if !assigned[partition] {
+			continue
+		}
+
+		lease, err := db.holdLease(partitionName(group.readGroup, partition))
+
+		if err != nil {
+			log.Printf("cannot acquire partition %d of %s. error: %s", partition, group.readGroup, err)
+			continue
+		}
+
+		if lease != nil && !group.owned[partition] {
+			group.owned[partition] = true
+			acquired = append(acquired, partition)
+		} else if lease == nil && group.owned[partition] {
+			delete(group.owned, partition)
+			revoked = append(revoked, partition)
+		}
+	}
+
+	group.rebalanced = true
+	onAssign, onRevoke := group.onAssign, group.onRevoke
+
+	group.lock.Unlock()
+
+	if len(revoked) > 0 && onRevoke != nil {
+		sort.Ints(revoked)
+		onRevoke(revoked)
+	}
+
+	if len(acquired) > 0 && onAssign != nil {
+		onAssign(acquired)
+	}
+
+	return nil
+}
+
+// rebalanceGroups rebalances every partitioned read group consumed by this node
+func (db *MySQLDriver) rebalanceGroups() {
+	db.groupLock.Lock()
+	groups := make([]*partitionGroup, 0, len(db.groups))
+	for _, group := range db.groups {
+		groups = append(groups, group)
+	}
+	db.groupLock.Unlock()
+
+	for _, group := range groups {
+		if err := db.rebalance(group); err != nil {
+			log.Printf("cannot rebalance %s. error: %s", group.readGroup, err)
+		}
+	}
+}
+
+// assignedPartitions returns partitions of this node given alive nodes
+func (db *MySQLDriver) assignedPartitions(nodes []string) map[int]bool {
+	index := sort.SearchStrings(nodes, db.config.NodeID)
+
+	// this node may not be registered yet
+	if index == len(nodes) || nodes[index] != db.config.NodeID {
+		nodes = append(nodes, "")
+		copy(nodes[index+1:], nodes[index:])
+		nodes[index] = db.config.NodeID
+	}
+
+	assigned := make(map[int]bool)
+
+	for partition := index; partition < db.config.Partitions; partition += len(nodes) {
+		assigned[partition] = true
+	}
+
+	return assigned
+}
+
+// aliveNodeIDs returns sorted ids of nodes with recent heartbeat
+func (db *MySQLDriver) aliveNodeIDs() ([]string, error) {
+	query := fmt.Sprintf(`SELECT node_id FROM %s WHERE last_seen >= now() - interval ? second ORDER BY node_id`, db.table("event_nodes"))
+
+	rows, err := db.db.Query(query, db.config.LockTimeoutSec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	nodes := make([]string, 0)
+
+	for rows.Next() {
+		var node string
+
+		if err = rows.Scan(&node); err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	// keep the same order as sort.SearchStrings regardless of database collation
+	sort.Strings(nodes)
+
+	return nodes, rows.Err()
+}
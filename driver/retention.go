@@ -0,0 +1,310 @@
+package driver
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pongsatt/go-dbevent"
+)
+
+var defaultRetentionBatchSize = 1000
+
+// RetentionPolicy represents which events of a type are purged. Each criterion set selects events on its own.
+type RetentionPolicy struct {
+	// EventType is type the policy applies to. Empty means every type.
+	EventType string
+	// MaxAge purges events created longer ago
+	MaxAge time.Duration
+	// MaxCount keeps only this many latest events of each type
+	MaxCount int
+	// Compact keeps only the latest event of each aggregate
+	Compact bool
+}
+
+// RetentionConfig represents retention configuration
+type RetentionConfig struct {
+	Policies []*RetentionPolicy
+	// Archiver receives purged events before they are deleted. Nil deletes without archiving.
+	Archiver dbevent.Archiver
+	// BatchSize is number of events archived and deleted at a time. Default is 1000.
+	BatchSize int
+}
+
+// PurgeStats represents result of purge
+type PurgeStats struct {
+	// SafeOffset is the highest id every read group has committed
+	SafeOffset uint
+	Purged     int
+}
+
+// retentionCondition represents where clause on events aliased e selecting events to purge
+type retentionCondition struct {
+	where  string
+	params []interface{}
+}
+
+// Purge deletes events selected by retention policies. Only events every read group has committed
+// are deleted, so nothing is purged until read groups have committed offsets.
+func (db *MySQLDriver) Purge(config *RetentionConfig) (*PurgeStats, error) {
+	if config.BatchSize == 0 {
+		config.BatchSize = defaultRetentionBatchSize
+	}
+
+	safeOffset, err := db.safeOffset()
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &PurgeStats{SafeOffset: safeOffset}
+
+	if safeOffset == 0 {
+		return stats, nil
+	}
+
+	for _, policy := range config.Policies {
+		conditions, err := db.retentionConditions(policy)
+
+		if err != nil {
+			return stats, err
+		}
+
+		for _, condition := range conditions {
+			purged, err := db.purge(safeOffset, condition, config)
+			stats.Purged += purged
+
+			if err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// safeOffset returns the lowest committed offset of every read group, leased or committed.
+// Partitioned read groups count only when every partition has committed.
+func (db *MySQLDriver) safeOffset() (uint, error) {
+	// a read group holding a lease without offset has not committed anything yet
+	query := fmt.Sprintf(`SELECT l.name, COALESCE(o.offset, 0) FROM %s l LEFT JOIN %s o ON o.name = l.name
+	UNION ALL SELECT name, offset FROM %s`, db.table("event_locks"), db.table("event_offsets"), db.table("event_offsets"))
+
+	rows, err := db.db.Query(query)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	offsets := make(map[string]uint)
+
+	for rows.Next() {
+		var name string
+		var offset uint
+
+		if err = rows.Scan(&name, &offset); err != nil {
+			return 0, err
+		}
+
+		if current, ok := offsets[name]; !ok || offset < current {
+			offsets[name] = offset
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return safeOffsetOf(offsets, db.config.Partitions), nil
+}
+
+// safeOffsetOf returns the lowest offset by read group name. It is 0 without read group or
+// when a partitioned read group misses a partition.
+func safeOffsetOf(offsets map[string]uint, partitions int) uint {
+	var safeOffset uint
+	found := false
+	partitionCounts := make(map[string]int)
+
+	for name, offset := range offsets {
+		if readGroup, partition := parsePartitionName(name); partition >= 0 {
+			partitionCounts[readGroup]++
+		}
+
+		if !found || offset < safeOffset {
+			safeOffset = offset
+			found = true
+		}
+	}
+
+	for _, count := range partitionCounts {
+		if count < partitions {
+			return 0
+		}
+	}
+
+	return safeOffset
+}
+
+func (db *MySQLDriver) retentionConditions(policy *RetentionPolicy) ([]*retentionCondition, error) {
+	typeWhere := ""
+	var typeParams []interface{}
+
+	if policy.EventType != "" {
+		typeWhere = " AND e.type = ?"
+		typeParams = []interface{}{policy.EventType}
+	}
+
+	conditions := make([]*retentionCondition, 0)
+
+	if policy.MaxAge > 0 {
+		conditions = append(conditions, &retentionCondition{
+			where:  "e.created_at < ?" + typeWhere,
+			params: append([]interface{}{db.config.Clock.Now().Add(-policy.MaxAge)}, typeParams...),
+		})
+	}
+
+	if policy.MaxCount > 0 {
+		countConditions, err := db.maxCountConditions(policy)
+
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, countConditions...)
+	}
+
+	if policy.Compact {
+		sameType := ""
+
+		if policy.EventType != "" {
+			sameType = " AND n.type = e.type"
+		}
+
+		conditions = append(conditions, &retentionCondition{
+			where: fmt.Sprintf(`EXISTS (SELECT 1 FROM %s n WHERE n.aggregate_type = e.aggregate_type
+			AND n.aggregate_id = e.aggregate_id AND n.id > e.id%s)`, db.table("events"), sameType) + typeWhere,
+			params: typeParams,
+		})
+	}
+
+	return conditions, nil
+}
+
+// maxCountConditions selects events older than the MaxCount latest of each type
+func (db *MySQLDriver) maxCountConditions(policy *RetentionPolicy) ([]*retentionCondition, error) {
+	types := []string{policy.EventType}
+
+	if policy.EventType == "" {
+		var err error
+
+		if types, err = db.eventTypes(); err != nil {
+			return nil, err
+		}
+	}
+
+	query := fmt.Sprintf(`SELECT id FROM %s WHERE type = ? ORDER BY id DESC LIMIT 1 OFFSET ?`, db.table("events"))
+	conditions := make([]*retentionCondition, 0, len(types))
+
+	for _, eventType := range types {
+		var cutoff uint
+
+		err := db.db.QueryRow(query, eventType, policy.MaxCount).Scan(&cutoff)
+
+		if err == sql.ErrNoRows {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, &retentionCondition{
+			where:  "e.type = ? AND e.id <= ?",
+			params: []interface{}{eventType, cutoff},
+		})
+	}
+
+	return conditions, nil
+}
+
+func (db *MySQLDriver) eventTypes() ([]string, error) {
+	rows, err := db.db.Query(fmt.Sprintf(`SELECT DISTINCT type FROM %s`, db.table("events")))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	types := make([]string, 0)
+
+	for rows.Next() {
+		var eventType string
+
+		if err = rows.Scan(&eventType); err != nil {
+			return nil, err
+		}
+
+		types = append(types, eventType)
+	}
+
+	return types, rows.Err()
+}
+
+// purge archives and deletes events matching condition up to safe offset in batches
+func (db *MySQLDriver) purge(safeOffset uint, condition *retentionCondition, config *RetentionConfig) (int, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s e WHERE e.id <= ? AND %s ORDER BY e.id LIMIT ?`,
+		eventColumns, db.table("events"), condition.where)
+
+	params := append([]interface{}{safeOffset}, condition.params...)
+	params = append(params, config.BatchSize)
+
+	purged := 0
+
+	for {
+		events, err := db.queryEvents(query, params...)
+
+		if err != nil {
+			return purged, err
+		}
+
+		if len(events) == 0 {
+			return purged, nil
+		}
+
+		if config.Archiver != nil {
+			if err = config.Archiver.Archive(events); err != nil {
+				return purged, err
+			}
+		}
+
+		if err = db.deleteEvents(events); err != nil {
+			return purged, err
+		}
+
+		purged += len(events)
+
+		if len(events) < config.BatchSize {
+			return purged, nil
+		}
+	}
+}
+
+func (db *MySQLDriver) deleteEvents(events []*dbevent.Event) error {
+	placeholders := make([]string, len(events))
+	ids := make([]interface{}, len(events))
+
+	for i, event := range events {
+		placeholders[i] = "?"
+		ids[i] = event.ID
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id IN (%s)`, db.table("events"), strings.Join(placeholders, ","))
+
+	_, err := db.db.Exec(query, ids...)
+
+	return err
+}
@@ -0,0 +1,50 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pongsatt/go-dbevent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeOffsetOf(t *testing.T) {
+	assert.Equal(t, uint(0), safeOffsetOf(map[string]uint{}, 0))
+	assert.Equal(t, uint(5), safeOffsetOf(map[string]uint{"group1": 10, "group2": 5}, 0))
+
+	// leased read group without offset
+	assert.Equal(t, uint(0), safeOffsetOf(map[string]uint{"group1": 10, "group2": 0}, 0))
+
+	// every partition committed
+	assert.Equal(t, uint(7), safeOffsetOf(map[string]uint{"group1#0": 7, "group1#1": 9}, 2))
+
+	// partition 1 never leased nor committed
+	assert.Equal(t, uint(0), safeOffsetOf(map[string]uint{"group1#0": 7}, 2))
+}
+
+func TestRetentionConditions(t *testing.T) {
+	now := time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+	db := &MySQLDriver{config: &MySQLStoreConfig{Clock: dbevent.NewFakeClock(now), TablePrefix: "app_"}}
+
+	conditions, err := db.retentionConditions(&RetentionPolicy{
+		EventType: "orderCreated",
+		MaxAge:    24 * time.Hour,
+		Compact:   true,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, conditions, 2)
+
+	assert.Equal(t, "e.created_at < ? AND e.type = ?", conditions[0].where)
+	assert.Equal(t, []interface{}{now.Add(-24 * time.Hour), "orderCreated"}, conditions[0].params)
+
+	assert.Contains(t, conditions[1].where, "FROM app_events n")
+	assert.Contains(t, conditions[1].where, "n.type = e.type")
+	assert.Equal(t, []interface{}{"orderCreated"}, conditions[1].params)
+
+	conditions, err = db.retentionConditions(&RetentionPolicy{Compact: true})
+
+	assert.NoError(t, err)
+	assert.NotContains(t, conditions[0].where, "n.type = e.type")
+	assert.Empty(t, conditions[0].params)
+}
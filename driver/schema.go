@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/pongsatt/go-dbevent"
+)
+
+// SaveSchema stores schema. It fails when the version of event type already exists.
+func (db *MySQLDriver) SaveSchema(schema *dbevent.Schema) error {
+	query := fmt.Sprintf(`INSERT INTO %s (event_type, version, definition) VALUES (?, ?, ?)`, db.table("event_schemas"))
+
+	_, err := db.db.Exec(query, schema.EventType, schema.Version, schema.Definition)
+
+	return err
+}
+
+// LoadSchemas returns every stored schema
+func (db *MySQLDriver) LoadSchemas() ([]*dbevent.Schema, error) {
+	query := fmt.Sprintf(`SELECT event_type, version, definition FROM %s ORDER BY event_type, version`, db.table("event_schemas"))
+
+	rows, err := db.db.Query(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	schemas := make([]*dbevent.Schema, 0)
+
+	for rows.Next() {
+		schema := new(dbevent.Schema)
+
+		if err = rows.Scan(&schema.EventType, &schema.Version, &schema.Definition); err != nil {
+			return nil, err
+		}
+
+		schemas = append(schemas, schema)
+	}
+
+	return schemas, rows.Err()
+}
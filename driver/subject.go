@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// SubjectKey returns latest key of subject, creating one when absent
+func (db *MySQLDriver) SubjectKey(subjectID string) (string, []byte, error) {
+	var keyID string
+	var key []byte
+
+	query := fmt.Sprintf(`SELECT key_id, key_data FROM %s WHERE subject_id = ? ORDER BY created_at DESC, key_id LIMIT 1`,
+		db.table("event_subject_keys"))
+
+	err := db.db.QueryRow(query, subjectID).Scan(&keyID, &key)
+
+	if err == nil {
+		return keyID, key, nil
+	}
+
+	if err != sql.ErrNoRows {
+		return "", nil, err
+	}
+
+	keyID = uuid.NewString()
+	key = make([]byte, 32)
+
+	if _, err = io.ReadFull(rand.Reader, key); err != nil {
+		return "", nil, err
+	}
+
+	query = fmt.Sprintf(`INSERT INTO %s (subject_id, key_id, key_data, created_at) VALUES (?, ?, ?, ?)`,
+		db.table("event_subject_keys"))
+
+	if _, err = db.db.Exec(query, subjectID, keyID, key, db.config.Clock.Now()); err != nil {
+		return "", nil, err
+	}
+
+	return keyID, key, nil
+}
+
+// LookupSubjectKey returns key of subject by id. Key is nil when subject was forgotten.
+func (db *MySQLDriver) LookupSubjectKey(subjectID string, keyID string) ([]byte, error) {
+	var key []byte
+
+	query := fmt.Sprintf(`SELECT key_data FROM %s WHERE subject_id = ? AND key_id = ?`, db.table("event_subject_keys"))
+
+	err := db.db.QueryRow(query, subjectID, keyID).Scan(&key)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// ForgetSubject deletes every key of subject
+func (db *MySQLDriver) ForgetSubject(subjectID string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE subject_id = ?`, db.table("event_subject_keys"))
+
+	_, err := db.db.Exec(query, subjectID)
+
+	return err
+}
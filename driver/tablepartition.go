@@ -0,0 +1,373 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TablePartitionNone keeps events table unpartitioned
+	TablePartitionNone = ""
+	// TablePartitionByTime partitions events table by range of created_at
+	TablePartitionByTime = "created_at"
+	// TablePartitionByID partitions events table by range of id
+	TablePartitionByID = "id"
+
+	tablePartitionTimeFormat = "20060102"
+	maxValuePartition        = "pmax"
+)
+
+var (
+	defaultTablePartitionDays           = 1
+	defaultTablePartitionSize           = 1000000
+	defaultTablePartitionsAhead         = 3
+	defaultTablePartitionMaintenanceSec = 3600
+)
+
+// tablePartition represents range partition of events table. Bound is exclusive upper id or
+// unix time. Partition pmax has bound 0.
+type tablePartition struct {
+	name  string
+	bound int64
+}
+
+// provisionTablePartitions partitions events table and adds partitions ahead while holding
+// migration lock so nodes starting together do not alter the table concurrently
+func (db *MySQLDriver) provisionTablePartitions() error {
+	return db.withMigrationLock(func() error {
+		if err := db.partitionTable(); err != nil {
+			return err
+		}
+
+		return db.maintainTablePartitionsLocked()
+	})
+}
+
+// withMigrationLock runs fn while holding migration lock
+func (db *MySQLDriver) withMigrationLock(fn func() error) error {
+	ctx := context.Background()
+	conn, err := db.db.Conn(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if err = db.lockMigration(ctx, conn); err != nil {
+		return err
+	}
+
+	defer db.unlockMigration(ctx, conn)
+
+	return fn()
+}
+
+// partitionTable converts events table to range partitions when configured and not partitioned yet
+func (db *MySQLDriver) partitionTable() error {
+	partitions, err := db.tablePartitions()
+
+	if err != nil || len(partitions) > 0 {
+		return err
+	}
+
+	var statements []string
+	var bounds []int64
+
+	switch db.config.TablePartitioning {
+	case TablePartitionByTime:
+		// every unique key must include partitioning column
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY, ADD PRIMARY KEY (id, created_at)", db.table("events")))
+		bounds = timeBounds(0, db.config.Clock.Now(), db.config.TablePartitionDays, db.config.TablePartitionsAhead)
+	case TablePartitionByID:
+		maxID, err := db.maxEventID()
+
+		if err != nil {
+			return err
+		}
+
+		bounds = idBounds(0, maxID, int64(db.config.TablePartitionSize), db.config.TablePartitionsAhead)
+	default:
+		return fmt.Errorf("unknown table partitioning %s", db.config.TablePartitioning)
+	}
+
+	definitions := make([]string, 0, len(bounds)+1)
+	for _, bound := range bounds {
+		definitions = append(definitions, db.partitionDefinition(bound))
+	}
+	definitions = append(definitions, fmt.Sprintf("PARTITION %s VALUES LESS THAN (MAXVALUE)", maxValuePartition))
+
+	statements = append(statements, fmt.Sprintf("ALTER TABLE %s PARTITION BY RANGE COLUMNS(%s) (%s)",
+		db.table("events"), db.config.TablePartitioning, strings.Join(definitions, ", ")))
+
+	for _, statement := range statements {
+		if _, err = db.db.Exec(statement); err != nil {
+			return fmt.Errorf("cannot partition events table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// MaintainTablePartitions adds partitions ahead of current time or id and drops partitions whose
+// events are older than retention and committed by every read group. It does nothing when events
+// table is not partitioned. Only one node maintains partitions at a time.
+func (db *MySQLDriver) MaintainTablePartitions() error {
+	return db.withMigrationLock(db.maintainTablePartitionsLocked)
+}
+
+func (db *MySQLDriver) maintainTablePartitionsLocked() error {
+	partitions, err := db.tablePartitions()
+
+	if err != nil || len(partitions) == 0 {
+		return err
+	}
+
+	if err = db.addTablePartitions(partitions); err != nil {
+		return err
+	}
+
+	if db.config.TablePartitionRetentionDays <= 0 {
+		return nil
+	}
+
+	return db.dropTablePartitions(partitions)
+}
+
+// maintainTablePartitions maintains partitions in background until driver is closed
+func (db *MySQLDriver) maintainTablePartitions() {
+	interval := time.Duration(db.config.TablePartitionMaintenanceSec) * time.Second
+
+	for {
+		select {
+		case <-db.closeChan:
+			return
+		case <-db.config.Clock.After(interval):
+			if err := db.MaintainTablePartitions(); err != nil {
+				log.Printf("cannot maintain events table partitions. error: %s", err)
+			}
+		}
+	}
+}
+
+func (db *MySQLDriver) addTablePartitions(partitions []*tablePartition) error {
+	var highest int64
+	for _, partition := range partitions {
+		if partition.bound > highest {
+			highest = partition.bound
+		}
+	}
+
+	var bounds []int64
+
+	switch db.config.TablePartitioning {
+	case TablePartitionByTime:
+		bounds = timeBounds(highest, db.config.Clock.Now(), db.config.TablePartitionDays, db.config.TablePartitionsAhead)
+	case TablePartitionByID:
+		maxID, err := db.maxEventID()
+
+		if err != nil {
+			return err
+		}
+
+		bounds = idBounds(highest, maxID, int64(db.config.TablePartitionSize), db.config.TablePartitionsAhead)
+	}
+
+	definitions := make([]string, 0, len(bounds)+1)
+	for _, bound := range bounds {
+		definitions = append(definitions, db.partitionDefinition(bound))
+	}
+
+	if len(definitions) == 0 {
+		return nil
+	}
+
+	definitions = append(definitions, fmt.Sprintf("PARTITION %s VALUES LESS THAN (MAXVALUE)", maxValuePartition))
+
+	query := fmt.Sprintf("ALTER TABLE %s REORGANIZE PARTITION %s INTO (%s)",
+		db.table("events"), maxValuePartition, strings.Join(definitions, ", "))
+
+	_, err := db.db.Exec(query)
+
+	return err
+}
+
+func (db *MySQLDriver) dropTablePartitions(partitions []*tablePartition) error {
+	safeOffset, err := db.safeOffset()
+
+	if err != nil || safeOffset == 0 {
+		return err
+	}
+
+	cutoff := db.config.Clock.Now().UTC().AddDate(0, 0, -db.config.TablePartitionRetentionDays)
+	expired := make([]string, 0)
+
+	for _, partition := range partitions {
+		if partition.name == maxValuePartition {
+			continue
+		}
+
+		var maxID sql.NullInt64
+		var maxCreatedAt sql.NullTime
+
+		query := fmt.Sprintf("SELECT MAX(id), MAX(created_at) FROM %s PARTITION (%s)", db.table("events"), partition.name)
+
+		if err = db.db.QueryRow(query).Scan(&maxID, &maxCreatedAt); err != nil {
+			return err
+		}
+
+		// never drop events a read group has not committed
+		if maxID.Valid && uint(maxID.Int64) > safeOffset {
+			break
+		}
+
+		if db.config.TablePartitioning == TablePartitionByTime && partition.bound > cutoff.Unix() {
+			break
+		}
+
+		if maxCreatedAt.Valid && !maxCreatedAt.Time.Before(cutoff) {
+			break
+		}
+
+		expired = append(expired, partition.name)
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s", db.table("events"), strings.Join(expired, ", "))
+
+	if _, err = db.db.Exec(query); err != nil {
+		return err
+	}
+
+	log.Printf("dropped events table partitions %s", strings.Join(expired, ", "))
+
+	return nil
+}
+
+// tablePartitions returns partitions of events table in order. It is empty when table is not partitioned.
+func (db *MySQLDriver) tablePartitions() ([]*tablePartition, error) {
+	query := `SELECT partition_name FROM information_schema.partitions
+	WHERE table_schema = DATABASE() AND table_name = ? AND partition_name IS NOT NULL
+	ORDER BY partition_ordinal_position`
+
+	rows, err := db.db.QueryContext(context.Background(), query, db.table("events"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	partitions := make([]*tablePartition, 0)
+
+	for rows.Next() {
+		partition := new(tablePartition)
+
+		if err = rows.Scan(&partition.name); err != nil {
+			return nil, err
+		}
+
+		if partition.bound, err = partitionBound(db.config.TablePartitioning, partition.name); err != nil {
+			return nil, err
+		}
+
+		partitions = append(partitions, partition)
+	}
+
+	return partitions, rows.Err()
+}
+
+// timeBounds returns bounds to add after highest existing bound in steps of days until ahead
+// partitions follow today. Without existing bound they start at the beginning of today.
+func timeBounds(highest int64, now time.Time, days int, ahead int) []int64 {
+	interval := int64(days) * 24 * 60 * 60
+	today := now.UTC().Truncate(24 * time.Hour).Unix()
+	target := today + int64(ahead)*interval
+
+	next := highest + interval
+	if highest == 0 {
+		next = today
+	}
+
+	bounds := make([]int64, 0)
+	for bound := next; bound <= target; bound += interval {
+		bounds = append(bounds, bound)
+	}
+
+	return bounds
+}
+
+// idBounds returns bounds to add after highest existing bound in steps of size until ahead
+// partitions follow the one holding max id. Without existing bound they start above max id.
+func idBounds(highest int64, maxID int64, size int64, ahead int) []int64 {
+	start := (maxID/size + 1) * size
+	target := start + int64(ahead)*size
+
+	next := highest + size
+	if highest == 0 {
+		next = start
+	}
+
+	bounds := make([]int64, 0)
+	for bound := next; bound <= target; bound += size {
+		bounds = append(bounds, bound)
+	}
+
+	return bounds
+}
+
+func (db *MySQLDriver) partitionDefinition(bound int64) string {
+	if db.config.TablePartitioning == TablePartitionByTime {
+		at := time.Unix(bound, 0).UTC()
+		return fmt.Sprintf("PARTITION p%s VALUES LESS THAN ('%s')", at.Format(tablePartitionTimeFormat), at.Format("2006-01-02 15:04:05"))
+	}
+
+	return fmt.Sprintf("PARTITION p%d VALUES LESS THAN (%d)", bound, bound)
+}
+
+// partitionBound parses bound from partition name p<yyyymmdd> or p<id>
+func partitionBound(partitioning string, name string) (int64, error) {
+	if name == maxValuePartition {
+		return 0, nil
+	}
+
+	value := strings.TrimPrefix(name, "p")
+
+	if partitioning == TablePartitionByTime {
+		at, err := time.Parse(tablePartitionTimeFormat, value)
+
+		if err != nil {
+			return 0, fmt.Errorf("unexpected events table partition %s", name)
+		}
+
+		return at.Unix(), nil
+	}
+
+	bound, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("unexpected events table partition %s", name)
+	}
+
+	return bound, nil
+}
+
+func (db *MySQLDriver) maxEventID() (int64, error) {
+	var maxID sql.NullInt64
+
+	query := fmt.Sprintf("SELECT MAX(id) FROM %s", db.table("events"))
+
+	if err := db.db.QueryRow(query).Scan(&maxID); err != nil {
+		return 0, err
+	}
+
+	return maxID.Int64, nil
+}
@@ -0,0 +1,52 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeBounds(t *testing.T) {
+	day := int64(24 * 60 * 60)
+	today := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	now := today.Add(13 * time.Hour)
+
+	// first run starts today
+	assert.Equal(t, []int64{today.Unix(), today.Unix() + 2*day, today.Unix() + 4*day}, timeBounds(0, now, 2, 2))
+
+	// next day continues from the tail in whole steps
+	tail := today.Unix() + 4*day
+	assert.Empty(t, timeBounds(tail, now.Add(24*time.Hour), 2, 2))
+	assert.Equal(t, []int64{tail + 2*day}, timeBounds(tail, now.Add(48*time.Hour), 2, 2))
+	assert.Equal(t, []int64{tail + 2*day, tail + 4*day}, timeBounds(tail, now.Add(96*time.Hour), 2, 2))
+}
+
+func TestIDBounds(t *testing.T) {
+	assert.Equal(t, []int64{100, 200, 300}, idBounds(0, 0, 100, 2))
+	assert.Equal(t, []int64{200, 300, 400}, idBounds(0, 150, 100, 2))
+
+	assert.Empty(t, idBounds(400, 150, 100, 2))
+	assert.Equal(t, []int64{500}, idBounds(400, 250, 100, 2))
+	assert.Equal(t, []int64{500, 600}, idBounds(400, 399, 100, 2))
+}
+
+func TestPartitionBound(t *testing.T) {
+	bound, err := partitionBound(TablePartitionByTime, "p20210110")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC).Unix(), bound)
+
+	bound, err = partitionBound(TablePartitionByID, "p1000")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), bound)
+
+	bound, err = partitionBound(TablePartitionByID, maxValuePartition)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), bound)
+
+	_, err = partitionBound(TablePartitionByTime, "p1000")
+	assert.Error(t, err)
+
+	_, err = partitionBound(TablePartitionByID, "pfoo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,369 @@
+package dbevent
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// MetadataEncryptionKeyID is metadata key of the id of key wrapping the data key
+	MetadataEncryptionKeyID = "encryption_key_id"
+	// MetadataEncryptionDataKey is metadata key of the wrapped data key
+	MetadataEncryptionDataKey = "encryption_data_key"
+)
+
+var (
+	// ErrUnknownKey is returned when event is encrypted with a key the encryptor does not have
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrNotSupported is returned when driver does not support the operation
+	ErrNotSupported = errors.New("operation not supported by driver")
+)
+
+// Encryptor represents event data encryption. Encrypt and Decrypt change event in place.
+type Encryptor interface {
+	Encrypt(event *Event) error
+	// Decrypt leaves event without encryption metadata unchanged
+	Decrypt(event *Event) error
+	// Rotated returns true when event should be encrypted again with the active key
+	Rotated(event *Event) bool
+}
+
+// StreamLoader is implemented by drivers loading events of an aggregate
+type StreamLoader interface {
+	LoadStream(aggregateType string, aggregateID string) ([]*Event, error)
+}
+
+// EventRewriter is implemented by drivers able to rewrite stored events
+type EventRewriter interface {
+	// ScanEvents returns events after id regardless of read group
+	ScanEvents(afterID uint, limit int) ([]*Event, error)
+	// UpdateEvents rewrites data and metadata of stored events
+	UpdateEvents(events ...*Event) error
+}
+
+// AESEncryptor represents AES-GCM envelope encryption. Every event is encrypted with a random
+// data key which is wrapped by a key encryption key and stored in event metadata.
+type AESEncryptor struct {
+	activeKeyID string
+	keys        map[string]cipher.AEAD
+}
+
+// NewAESEncryptor creates new instance. Keys are 16, 24 or 32 bytes by key id. New events are
+// encrypted with active key while other keys still decrypt events written before rotation.
+func NewAESEncryptor(activeKeyID string, keys map[string][]byte) (*AESEncryptor, error) {
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active key %s not found", activeKeyID)
+	}
+
+	encryptor := &AESEncryptor{
+		activeKeyID: activeKeyID,
+		keys:        make(map[string]cipher.AEAD),
+	}
+
+	for keyID, key := range keys {
+		aead, err := newGCM(key)
+
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", keyID, err)
+		}
+
+		encryptor.keys[keyID] = aead
+	}
+
+	return encryptor, nil
+}
+
+// Encrypt replaces data with encrypted data as JSON string
+func (encryptor *AESEncryptor) Encrypt(event *Event) error {
+	if len(event.Data) == 0 {
+		return nil
+	}
+
+	if _, ok := event.Metadata[MetadataEncryptionKeyID]; ok {
+		return fmt.Errorf("event already encrypted")
+	}
+
+	dataKey := make([]byte, 32)
+
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return err
+	}
+
+	dataAEAD, err := newGCM(dataKey)
+
+	if err != nil {
+		return err
+	}
+
+	wrappedKey, err := seal(encryptor.keys[encryptor.activeKeyID], dataKey, []byte(encryptor.activeKeyID))
+
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := seal(dataAEAD, event.Data, associatedData(event))
+
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(base64.StdEncoding.EncodeToString(encrypted))
+
+	if err != nil {
+		return err
+	}
+
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string)
+	}
+
+	event.Data = data
+	event.Metadata[MetadataEncryptionKeyID] = encryptor.activeKeyID
+	event.Metadata[MetadataEncryptionDataKey] = base64.StdEncoding.EncodeToString(wrappedKey)
+
+	return nil
+}
+
+// Decrypt restores data and removes encryption metadata
+func (encryptor *AESEncryptor) Decrypt(event *Event) error {
+	keyID, ok := event.Metadata[MetadataEncryptionKeyID]
+
+	if !ok {
+		return nil
+	}
+
+	keyAEAD, ok := encryptor.keys[keyID]
+
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(event.Metadata[MetadataEncryptionDataKey])
+
+	if err != nil {
+		return err
+	}
+
+	dataKey, err := open(keyAEAD, wrappedKey, []byte(keyID))
+
+	if err != nil {
+		return err
+	}
+
+	dataAEAD, err := newGCM(dataKey)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := openData(dataAEAD, event)
+
+	if err != nil {
+		return err
+	}
+
+	event.Data = data
+	delete(event.Metadata, MetadataEncryptionKeyID)
+	delete(event.Metadata, MetadataEncryptionDataKey)
+
+	return nil
+}
+
+// Rotated returns true when event is plaintext or encrypted with a key other than the active one
+func (encryptor *AESEncryptor) Rotated(event *Event) bool {
+	if len(event.Data) == 0 {
+		return false
+	}
+
+	return event.Metadata[MetadataEncryptionKeyID] != encryptor.activeKeyID
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext and prepends nonce
+func seal(aead cipher.AEAD, plaintext []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts ciphertext prefixed with nonce
+func open(aead cipher.AEAD, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce := ciphertext[:aead.NonceSize()]
+
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], additionalData)
+}
+
+// associatedData binds encrypted data to its aggregate so it cannot be moved to another one
+func associatedData(event *Event) []byte {
+	return []byte(event.AggregateType + "/" + event.AggregateID)
+}
+
+// encryptingDriver represents store driver encrypting data on create and decrypting on read
+type encryptingDriver struct {
+	StoreDriver
+	encryptor Encryptor
+}
+
+// Create encrypts copies of events so caller keeps plaintext
+func (driver *encryptingDriver) Create(events ...*Event) error {
+	encrypted := make([]*Event, len(events))
+
+	for i, event := range events {
+		copied := *event
+		copied.Metadata = copyMetadata(event.Metadata)
+
+		if err := driver.encryptor.Encrypt(&copied); err != nil {
+			return err
+		}
+
+		encrypted[i] = &copied
+	}
+
+	if err := driver.StoreDriver.Create(encrypted...); err != nil {
+		return err
+	}
+
+	for i, event := range events {
+		event.TenantID = encrypted[i].TenantID
+		event.CreatedAt = encrypted[i].CreatedAt
+	}
+
+	return nil
+}
+
+// Fetch fetches and decrypts events
+func (driver *encryptingDriver) Fetch(readGroup string, limit int) ([]*Event, error) {
+	events, err := driver.StoreDriver.Fetch(readGroup, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, driver.decrypt(events)
+}
+
+// LoadStream loads and decrypts events of aggregate
+func (driver *encryptingDriver) LoadStream(aggregateType string, aggregateID string) ([]*Event, error) {
+	events, err := loadStream(driver.StoreDriver, aggregateType, aggregateID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, driver.decrypt(events)
+}
+
+// WatchPartitions forwards to driver when it supports partitions
+func (driver *encryptingDriver) WatchPartitions(readGroup string, onAssign func(partitions []int), onRevoke func(partitions []int)) {
+	if watcher, ok := driver.StoreDriver.(PartitionWatcher); ok {
+		watcher.WatchPartitions(readGroup, onAssign, onRevoke)
+	}
+}
+
+func (driver *encryptingDriver) decrypt(events []*Event) error {
+	for _, event := range events {
+		if err := driver.encryptor.Decrypt(event); err != nil {
+			return fmt.Errorf("cannot decrypt event %d: %w", event.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// reEncrypt rewrites events encrypted with rotated keys using the active key.
+// It returns number of rewritten events.
+func (driver *encryptingDriver) reEncrypt(batchSize int) (int, error) {
+	rewriter, ok := driver.StoreDriver.(EventRewriter)
+
+	if !ok {
+		return 0, ErrNotSupported
+	}
+
+	var afterID uint
+	count := 0
+
+	for {
+		events, err := rewriter.ScanEvents(afterID, batchSize)
+
+		if err != nil {
+			return count, err
+		}
+
+		if len(events) == 0 {
+			return count, nil
+		}
+
+		afterID = events[len(events)-1].ID
+		rotated := make([]*Event, 0, len(events))
+
+		for _, event := range events {
+			if !driver.encryptor.Rotated(event) {
+				continue
+			}
+
+			if err = driver.encryptor.Decrypt(event); err != nil {
+				return count, fmt.Errorf("cannot decrypt event %d: %w", event.ID, err)
+			}
+
+			if err = driver.encryptor.Encrypt(event); err != nil {
+				return count, err
+			}
+
+			rotated = append(rotated, event)
+		}
+
+		if len(rotated) > 0 {
+			if err = rewriter.UpdateEvents(rotated...); err != nil {
+				return count, err
+			}
+
+			count += len(rotated)
+		}
+	}
+}
+
+func loadStream(driver StoreDriver, aggregateType string, aggregateID string) ([]*Event, error) {
+	loader, ok := driver.(StreamLoader)
+
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	return loader.LoadStream(aggregateType, aggregateID)
+}
+
+func copyMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+
+	return copied
+}
@@ -0,0 +1,149 @@
+package dbevent
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{1}, 32)
+	testKey2 = bytes.Repeat([]byte{2}, 32)
+)
+
+type rewriterStoreDriver struct {
+	*MockStoreDriver
+	events  []*Event
+	updated []*Event
+}
+
+func (driver *rewriterStoreDriver) ScanEvents(afterID uint, limit int) ([]*Event, error) {
+	events := make([]*Event, 0)
+
+	for _, event := range driver.events {
+		if event.ID > afterID && len(events) < limit {
+			copied := *event
+			copied.Metadata = copyMetadata(event.Metadata)
+			events = append(events, &copied)
+		}
+	}
+
+	return events, nil
+}
+
+func (driver *rewriterStoreDriver) UpdateEvents(events ...*Event) error {
+	driver.updated = append(driver.updated, events...)
+	return nil
+}
+
+func TestAESEncryptor_EncryptDecrypt(t *testing.T) {
+	encryptor, err := NewAESEncryptor("key1", map[string][]byte{"key1": testKey1})
+	assert.NoError(t, err)
+
+	event := &Event{AggregateType: "user", AggregateID: "1", Data: JSON(`{"name":"john"}`)}
+
+	err = encryptor.Encrypt(event)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(event.Data), "john")
+	assert.Equal(t, "key1", event.Metadata[MetadataEncryptionKeyID])
+	assert.False(t, encryptor.Rotated(event))
+
+	err = encryptor.Decrypt(event)
+
+	assert.NoError(t, err)
+	assert.Equal(t, JSON(`{"name":"john"}`), event.Data)
+	assert.Empty(t, event.Metadata)
+}
+
+func TestAESEncryptor_Rotation(t *testing.T) {
+	oldEncryptor, _ := NewAESEncryptor("key1", map[string][]byte{"key1": testKey1})
+	encryptor, err := NewAESEncryptor("key2", map[string][]byte{"key1": testKey1, "key2": testKey2})
+	assert.NoError(t, err)
+
+	event := &Event{AggregateType: "user", AggregateID: "1", Data: JSON(`{"name":"john"}`)}
+	assert.NoError(t, oldEncryptor.Encrypt(event))
+
+	assert.True(t, encryptor.Rotated(event))
+	assert.NoError(t, encryptor.Decrypt(event))
+	assert.Equal(t, JSON(`{"name":"john"}`), event.Data)
+}
+
+func TestAESEncryptor_DecryptError(t *testing.T) {
+	encryptor, _ := NewAESEncryptor("key1", map[string][]byte{"key1": testKey1})
+	otherEncryptor, _ := NewAESEncryptor("key2", map[string][]byte{"key2": testKey2})
+
+	event := &Event{AggregateType: "user", AggregateID: "1", Data: JSON(`{"name":"john"}`)}
+	assert.NoError(t, encryptor.Encrypt(event))
+
+	assert.Equal(t, ErrUnknownKey, otherEncryptor.Decrypt(event))
+
+	// encrypted data cannot be moved to another aggregate
+	event.AggregateID = "2"
+	assert.Error(t, encryptor.Decrypt(event))
+}
+
+func TestNewAESEncryptor_Error(t *testing.T) {
+	_, err := NewAESEncryptor("key2", map[string][]byte{"key1": testKey1})
+	assert.Error(t, err)
+
+	_, err = NewAESEncryptor("key1", map[string][]byte{"key1": []byte("short")})
+	assert.Error(t, err)
+}
+
+func TestStore_WithEncryptor(t *testing.T) {
+	encryptor, _ := NewAESEncryptor("key1", map[string][]byte{"key1": testKey1})
+	mockDriver := new(MockStoreDriver)
+
+	var stored []*Event
+	mockDriver.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		stored = append(stored, args.Get(0).(*Event))
+	}).Return(nil)
+
+	store := newStore(mockDriver).WithEncryptor(encryptor)
+	event := &Event{ID: 1, AggregateType: "user", AggregateID: "1", Data: JSON(`{"name":"john"}`)}
+
+	err := store.Produce(event)
+
+	assert.NoError(t, err)
+	assert.Equal(t, JSON(`{"name":"john"}`), event.Data)
+	if assert.Len(t, stored, 1) {
+		assert.NotContains(t, string(stored[0].Data), "john")
+	}
+
+	mockDriver.On("Fetch", "group1", 10).Return(stored, nil)
+
+	fetched, err := store.driver.Fetch("group1", 10)
+
+	assert.NoError(t, err)
+	if assert.Len(t, fetched, 1) {
+		assert.Equal(t, JSON(`{"name":"john"}`), fetched[0].Data)
+	}
+}
+
+func TestStore_ReEncrypt(t *testing.T) {
+	oldEncryptor, _ := NewAESEncryptor("key1", map[string][]byte{"key1": testKey1})
+	encryptor, _ := NewAESEncryptor("key2", map[string][]byte{"key1": testKey1, "key2": testKey2})
+
+	event1 := &Event{ID: 1, AggregateType: "user", AggregateID: "1", Data: JSON(`{"name":"john"}`)}
+	event2 := &Event{ID: 2, AggregateType: "user", AggregateID: "2", Data: JSON(`{"name":"jane"}`)}
+	event3 := &Event{ID: 3, AggregateType: "user", AggregateID: "3", Data: JSON(`{"name":"jim"}`)}
+	assert.NoError(t, oldEncryptor.Encrypt(event1))
+	assert.NoError(t, encryptor.Encrypt(event2))
+
+	driver := &rewriterStoreDriver{MockStoreDriver: new(MockStoreDriver), events: []*Event{event1, event2, event3}}
+	store := newStore(driver).WithEncryptor(encryptor)
+
+	count, err := store.ReEncrypt(2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	if assert.Len(t, driver.updated, 2) {
+		assert.Equal(t, uint(1), driver.updated[0].ID)
+		assert.Equal(t, "key2", driver.updated[0].Metadata[MetadataEncryptionKeyID])
+		assert.Equal(t, uint(3), driver.updated[1].ID)
+		assert.Equal(t, "key2", driver.updated[1].Metadata[MetadataEncryptionKeyID])
+	}
+}
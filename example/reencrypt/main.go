@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	// we need it
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/pongsatt/go-dbevent"
+	"github.com/pongsatt/go-dbevent/driver"
+)
+
+// usage: reencrypt <active key id> <key id>=<hex key> [<key id>=<hex key>...]
+func main() {
+	if len(os.Args) < 3 {
+		panic("active key id and keys required")
+	}
+
+	keys := make(map[string][]byte)
+
+	for _, arg := range os.Args[2:] {
+		parts := strings.SplitN(arg, "=", 2)
+
+		if len(parts) != 2 {
+			panic(fmt.Sprintf("invalid key %s", arg))
+		}
+
+		key, err := hex.DecodeString(parts[1])
+
+		if err != nil {
+			panic(err)
+		}
+
+		keys[parts[0]] = key
+	}
+
+	encryptor, err := dbevent.NewAESEncryptor(os.Args[1], keys)
+
+	if err != nil {
+		panic(err)
+	}
+
+	dbConfig := &dbevent.DBConfig{
+		Host:     "127.0.0.1",
+		Port:     3306,
+		DBName:   "testdb",
+		User:     "root",
+		Password: "my-secret-pw",
+	}
+
+	mysqlDriver := driver.NewMySQLEventDriver(dbConfig, &driver.MySQLStoreConfig{ChangeDetection: driver.ChangeDetectionNone})
+
+	eventStore := dbevent.NewStore(mysqlDriver).WithEncryptor(encryptor)
+	defer eventStore.Close()
+
+	count, err := eventStore.ReEncrypt(100)
+
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%d events re-encrypted with key '%s'\n", count, os.Args[1])
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	// we need it
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/pongsatt/go-dbevent"
+	"github.com/pongsatt/go-dbevent/driver"
+)
+
+// usage: retention <archive dir>
+func main() {
+	if len(os.Args) < 2 {
+		panic("archive dir required")
+	}
+
+	dbConfig := &dbevent.DBConfig{
+		Host:     "127.0.0.1",
+		Port:     3306,
+		DBName:   "testdb",
+		User:     "root",
+		Password: "my-secret-pw",
+	}
+
+	mysqlDriver := driver.NewMySQLEventDriver(dbConfig, &driver.MySQLStoreConfig{ChangeDetection: driver.ChangeDetectionNone})
+	defer mysqlDriver.Close()
+
+	if err := mysqlDriver.Provision(); err != nil {
+		panic(err)
+	}
+
+	stats, err := mysqlDriver.Purge(&driver.RetentionConfig{
+		Policies: []*driver.RetentionPolicy{
+			{MaxAge: 30 * 24 * time.Hour},
+			{EventType: "testtype", Compact: true},
+		},
+		Archiver: dbevent.NewJSONLArchiver(os.Args[1]),
+	})
+
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf("%d events purged up to offset %d\n", stats.Purged, stats.SafeOffset)
+}
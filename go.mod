@@ -4,8 +4,12 @@ go 1.16
 
 require (
 	github.com/go-sql-driver/mysql v1.5.0
+	github.com/golang/protobuf v1.3.2
 	github.com/google/uuid v1.2.0
+	github.com/klauspost/compress v1.11.13
 	github.com/siddontang/go-mysql v1.1.0
-	github.com/stretchr/testify v1.7.0 // indirect
+	github.com/stretchr/testify v1.7.0
 	github.com/vektra/mockery/v2 v2.7.4 // indirect
+	github.com/vmihailenco/msgpack/v5 v5.3.0
+	github.com/xeipuuv/gojsonschema v1.2.0
 )
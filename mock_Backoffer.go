@@ -9,6 +9,20 @@ type MockBackoffer struct {
 	mock.Mock
 }
 
+// Exhausted provides a mock function with given fields:
+func (_m *MockBackoffer) Exhausted() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // ResetSleepBackoff provides a mock function with given fields:
 func (_m *MockBackoffer) ResetSleepBackoff() {
 	_m.Called()
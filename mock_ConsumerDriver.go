@@ -13,6 +13,20 @@ type MockConsumerDriver struct {
 	mock.Mock
 }
 
+// CommitBatchInTrans provides a mock function with given fields: readGroup, events, handler
+func (_m *MockConsumerDriver) CommitBatchInTrans(readGroup string, events []*Event, handler func() error) error {
+	ret := _m.Called(readGroup, events, handler)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, []*Event, func() error) error); ok {
+		r0 = rf(readGroup, events, handler)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CommitInTrans provides a mock function with given fields: readGroup, event, handler
 func (_m *MockConsumerDriver) CommitInTrans(readGroup string, event *Event, handler func() error) error {
 	ret := _m.Called(readGroup, event, handler)
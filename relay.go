@@ -0,0 +1,71 @@
+package dbevent
+
+import (
+	"context"
+)
+
+// Relay publishes events to sinks. Each sink consumes with its own read group.
+type Relay struct {
+	driver ConsumerDriver
+	config *ConsumerConfig
+	routes []*relayRoute
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// relayRoute represents sink and its consumer
+type relayRoute struct {
+	sink     Sink
+	consumer *Consumer
+}
+
+// NewRelay creates new relay
+func NewRelay(driver ConsumerDriver, config *ConsumerConfig) *Relay {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Relay{
+		driver: driver,
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Add registers sink consuming as read group
+func (relay *Relay) Add(readGroup string, sink Sink) *Relay {
+	relay.routes = append(relay.routes, &relayRoute{
+		sink:     sink,
+		consumer: NewConsumer(readGroup, relay.driver, relay.config),
+	})
+
+	return relay
+}
+
+// Start relaying events to every sink. Offset is committed only when sink succeeds.
+func (relay *Relay) Start() {
+	for _, route := range relay.routes {
+		sink := route.sink
+
+		route.consumer.ConsumeBatch(func(events []*Event) error {
+			return sink.Publish(relay.ctx, events)
+		})
+	}
+}
+
+// Close relay
+func (relay *Relay) Close() {
+	relay.cancel()
+
+	for _, route := range relay.routes {
+		route.consumer.Close()
+	}
+}
+
+// CloseAndWait closes relay and wait it to be done
+func (relay *Relay) CloseAndWait() {
+	relay.cancel()
+
+	for _, route := range relay.routes {
+		route.consumer.CloseAndWait()
+	}
+}
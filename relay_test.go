@@ -0,0 +1,94 @@
+package dbevent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestRelay(driver ConsumerDriver, readGroup string, sink Sink) (*Relay, *MockBackoffer, *MockBackoffer) {
+	mockFetchBackoffer := &MockBackoffer{}
+	mockPublishBackoffer := &MockBackoffer{}
+
+	relay := NewRelay(driver, &ConsumerConfig{}).Add(readGroup, sink)
+	relay.routes[0].consumer.fetchBackoff = mockFetchBackoffer
+	relay.routes[0].consumer.handlerBackoff = mockPublishBackoffer
+
+	return relay, mockFetchBackoffer, mockPublishBackoffer
+}
+
+func TestRelay_Publish(t *testing.T) {
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+	events := []*Event{{ID: 1}, {ID: 2}}
+
+	var relay *Relay
+	var published []*Event
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	sink := SinkFunc(func(ctx context.Context, events []*Event) error {
+		published = events
+		relay.Close() // run only once
+		wg.Done()
+		return nil
+	})
+
+	relay, mockFetchBackoffer, mockPublishBackoffer := newTestRelay(mockDriver, readGroup, sink)
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockPublishBackoffer.On("ResetSleepBackoff")
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitBatchInTrans", readGroup, events, mock.Anything).
+		Return(func(readGroup string, events []*Event, handler func() error) error {
+			return handler()
+		})
+
+	relay.Start()
+	wg.Wait()
+	relay.CloseAndWait()
+
+	assert.Equal(t, events, published)
+	mockDriver.AssertNumberOfCalls(t, "CommitBatchInTrans", 1)
+	mockPublishBackoffer.AssertNumberOfCalls(t, "ResetSleepBackoff", 1)
+}
+
+func TestRelay_PublishError(t *testing.T) {
+	mockDriver := &MockConsumerDriver{}
+
+	readGroup := "testGroup"
+	events := []*Event{{ID: 1}}
+
+	sink := SinkFunc(func(ctx context.Context, events []*Event) error {
+		return errors.New("mock error")
+	})
+
+	relay, mockFetchBackoffer, mockPublishBackoffer := newTestRelay(mockDriver, readGroup, sink)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	mockFetchBackoffer.On("ResetSleepBackoff")
+	mockPublishBackoffer.On("Exhausted").Return(false)
+	mockPublishBackoffer.On("SleepBackoff").Once().Run(func(args mock.Arguments) {
+		relay.Close()
+		wg.Done()
+	})
+	mockDriver.On("Fetch", readGroup, mock.Anything).Return(events, nil)
+	mockDriver.On("CommitBatchInTrans", readGroup, events, mock.Anything).
+		Return(func(readGroup string, events []*Event, handler func() error) error {
+			return handler()
+		})
+
+	relay.Start()
+	wg.Wait()
+	relay.CloseAndWait()
+
+	mockPublishBackoffer.AssertNumberOfCalls(t, "SleepBackoff", 1)
+	mockPublishBackoffer.AssertNumberOfCalls(t, "ResetSleepBackoff", 0)
+}
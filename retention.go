@@ -0,0 +1,110 @@
+package dbevent
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Archiver represents storage of events purged from store
+type Archiver interface {
+	Archive(events []*Event) error
+}
+
+// JSONLArchiver represents archiver writing each batch of events to a gzip compressed
+// JSON lines file named events-<first id>-<last id>.jsonl.gz
+type JSONLArchiver struct {
+	dir string
+}
+
+// NewJSONLArchiver creates new archiver writing files into dir
+func NewJSONLArchiver(dir string) *JSONLArchiver {
+	return &JSONLArchiver{dir: dir}
+}
+
+// Archive writes events into a new file. The file appears only when completely written.
+func (archiver *JSONLArchiver) Archive(events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(archiver.dir, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("events-%d-%d.jsonl.gz", events[0].ID, events[len(events)-1].ID)
+
+	file, err := ioutil.TempFile(archiver.dir, name+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	if err = writeJSONL(file, events); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), filepath.Join(archiver.dir, name))
+}
+
+func writeJSONL(file *os.File, events []*Event) error {
+	w := gzip.NewWriter(file)
+	encoder := json.NewEncoder(w)
+
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// ReadArchive returns events of file written by JSONLArchiver
+func ReadArchive(path string) ([]*Event, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	r, err := gzip.NewReader(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	events := make([]*Event, 0)
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	for decoder.More() {
+		event := new(Event)
+
+		if err = decoder.Decode(event); err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
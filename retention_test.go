@@ -0,0 +1,38 @@
+package dbevent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLArchiver_Archive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	createdAt := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []*Event{
+		{ID: 1, Type: "orderCreated", AggregateType: "order", AggregateID: "order1", Data: JSON(`{"total":10}`), CreatedAt: &createdAt},
+		{ID: 3, Type: "orderPaid", AggregateType: "order", AggregateID: "order1", ContentType: ContentTypeMessagePack,
+			Data: []byte{0x80}, Metadata: map[string]string{"source": "web"}, CreatedAt: &createdAt},
+	}
+
+	archiver := NewJSONLArchiver(dir)
+
+	assert.NoError(t, archiver.Archive(events))
+	assert.NoError(t, archiver.Archive(nil))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "events-1-3.jsonl.gz", files[0].Name())
+
+	archived, err := ReadArchive(filepath.Join(dir, files[0].Name()))
+
+	assert.NoError(t, err)
+	assert.Equal(t, events, archived)
+}
@@ -0,0 +1,130 @@
+package dbevent
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// RetryPolicy represents algorithm computing delay before next retry
+type RetryPolicy interface {
+	// NextBackoffMs returns delay of attempt (starting at 1) given delay of the previous attempt
+	NextBackoffMs(attempt int, previousMs int, rnd *rand.Rand) int
+}
+
+// ExponentialPolicy grows delay by multiplier. Each delay is reduced by up to RandomFactor.
+type ExponentialPolicy struct {
+	InitialMs    int
+	Multiplier   int
+	RandomFactor float32
+}
+
+// NextBackoffMs returns exponential delay
+func (policy *ExponentialPolicy) NextBackoffMs(attempt int, previousMs int, rnd *rand.Rand) int {
+	baseMs := float64(policy.InitialMs) * math.Pow(float64(policy.Multiplier), float64(attempt-1))
+
+	if attempt == 1 || policy.RandomFactor <= 0 {
+		return clampMs(baseMs)
+	}
+
+	minMs := baseMs * (1 - float64(policy.RandomFactor))
+
+	return clampMs(minMs + rnd.Float64()*(baseMs-minMs))
+}
+
+// ConstantPolicy always waits the same delay
+type ConstantPolicy struct {
+	DelayMs int
+}
+
+// NextBackoffMs returns constant delay
+func (policy *ConstantPolicy) NextBackoffMs(attempt int, previousMs int, rnd *rand.Rand) int {
+	return policy.DelayMs
+}
+
+// LinearPolicy grows delay by fixed increment
+type LinearPolicy struct {
+	InitialMs   int
+	IncrementMs int
+}
+
+// NextBackoffMs returns linear delay
+func (policy *LinearPolicy) NextBackoffMs(attempt int, previousMs int, rnd *rand.Rand) int {
+	return clampMs(float64(policy.InitialMs) + float64(policy.IncrementMs)*float64(attempt-1))
+}
+
+// DecorrelatedJitterPolicy picks delay randomly between base and three times the previous delay
+type DecorrelatedJitterPolicy struct {
+	BaseMs int
+}
+
+// NextBackoffMs returns decorrelated jitter delay
+func (policy *DecorrelatedJitterPolicy) NextBackoffMs(attempt int, previousMs int, rnd *rand.Rand) int {
+	if previousMs < policy.BaseMs {
+		previousMs = policy.BaseMs
+	}
+
+	maxMs := float64(previousMs) * 3
+
+	return clampMs(float64(policy.BaseMs) + rnd.Float64()*(maxMs-float64(policy.BaseMs)))
+}
+
+// FullJitterPolicy picks delay randomly between zero and the exponential delay
+type FullJitterPolicy struct {
+	BaseMs int
+	// CapMs limits the exponential delay before jitter is applied. 0 means no limit.
+	CapMs int
+}
+
+// NextBackoffMs returns full jitter delay
+func (policy *FullJitterPolicy) NextBackoffMs(attempt int, previousMs int, rnd *rand.Rand) int {
+	maxMs := float64(policy.BaseMs) * math.Pow(2, float64(attempt-1))
+
+	if policy.CapMs > 0 && maxMs > float64(policy.CapMs) {
+		maxMs = float64(policy.CapMs)
+	}
+
+	return clampMs(rnd.Float64() * maxMs)
+}
+
+func clampMs(ms float64) int {
+	if ms > math.MaxInt32 {
+		return math.MaxInt32
+	}
+
+	return int(ms)
+}
+
+// permanentError marks error as not retryable
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent wraps error so it is not retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns true if error is marked as permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+
+	return errors.As(err, &permanent)
+}
+
+// IsRetryable is the default error classification. Every error except permanent one is retryable.
+func IsRetryable(err error) bool {
+	return !IsPermanent(err)
+}
@@ -0,0 +1,76 @@
+package dbevent
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Constant(t *testing.T) {
+	policy := &ConstantPolicy{DelayMs: 500}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		assert.Equal(t, 500, policy.NextBackoffMs(attempt, 0, nil))
+	}
+}
+
+func TestRetryPolicy_Linear(t *testing.T) {
+	policy := &LinearPolicy{InitialMs: 1000, IncrementMs: 500}
+
+	assert.Equal(t, 1000, policy.NextBackoffMs(1, 0, nil))
+	assert.Equal(t, 1500, policy.NextBackoffMs(2, 1000, nil))
+	assert.Equal(t, 2000, policy.NextBackoffMs(3, 1500, nil))
+}
+
+func TestRetryPolicy_DecorrelatedJitter(t *testing.T) {
+	policy := &DecorrelatedJitterPolicy{BaseMs: 100}
+	rnd := rand.New(rand.NewSource(1))
+
+	previous := 0
+	for attempt := 1; attempt <= 10; attempt++ {
+		v := policy.NextBackoffMs(attempt, previous, rnd)
+
+		maxMs := previous * 3
+		if maxMs < 300 {
+			maxMs = 300
+		}
+
+		if v < 100 || v > maxMs {
+			t.Errorf("expect backoff to be between 100 and %d but %d", maxMs, v)
+		}
+
+		previous = v
+	}
+}
+
+func TestRetryPolicy_FullJitter(t *testing.T) {
+	policy := &FullJitterPolicy{BaseMs: 100, CapMs: 1000}
+	rnd := rand.New(rand.NewSource(1))
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		v := policy.NextBackoffMs(attempt, 0, rnd)
+
+		maxMs := 100 << uint(attempt-1)
+		if maxMs > 1000 {
+			maxMs = 1000
+		}
+
+		if v < 0 || v > maxMs {
+			t.Errorf("expect backoff to be between 0 and %d but %d", maxMs, v)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	mockErr := errors.New("mock error")
+
+	assert.Nil(t, Permanent(nil))
+	assert.False(t, IsPermanent(mockErr))
+	assert.True(t, IsPermanent(Permanent(mockErr)))
+	assert.True(t, IsPermanent(fmt.Errorf("wrapped: %w", Permanent(mockErr))))
+	assert.True(t, errors.Is(Permanent(mockErr), mockErr))
+	assert.False(t, IsRetryable(Permanent(mockErr)))
+}
@@ -0,0 +1,348 @@
+package dbevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// schemaFilePattern matches schema file name <event type>.v<version>.json
+var schemaFilePattern = regexp.MustCompile(`^(.+)\.v(\d+)\.json$`)
+
+// Schema represents JSON Schema of an event type version
+type Schema struct {
+	EventType  string
+	Version    int
+	Definition string
+}
+
+// SchemaStore represents persistent storage of schemas
+type SchemaStore interface {
+	SaveSchema(schema *Schema) error
+	LoadSchemas() ([]*Schema, error)
+}
+
+// ValidationError represents event not matching its schema
+type ValidationError struct {
+	EventType string
+	Version   int
+	Errors    []string
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("event %s does not match schema version %d: %s", err.EventType, err.Version, strings.Join(err.Errors, "; "))
+}
+
+// SchemaRegistry represents JSON schemas of event types by version
+type SchemaRegistry struct {
+	// registerLock serializes registration so versions are checked against the latest one
+	registerLock sync.Mutex
+	lock         sync.RWMutex
+	store        SchemaStore
+	schemas      map[string][]*registeredSchema
+}
+
+type registeredSchema struct {
+	*Schema
+	compiled *gojsonschema.Schema
+}
+
+// NewSchemaRegistry creates new registry loading schemas from store. Store may be nil
+// to keep schemas in memory only.
+func NewSchemaRegistry(store SchemaStore) (*SchemaRegistry, error) {
+	registry := &SchemaRegistry{
+		store:   store,
+		schemas: make(map[string][]*registeredSchema),
+	}
+
+	if store == nil {
+		return registry, nil
+	}
+
+	schemas, err := store.LoadSchemas()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schema := range schemas {
+		registered, err := compileSchema(schema)
+
+		if err != nil {
+			return nil, err
+		}
+
+		registry.add(registered)
+	}
+
+	return registry, nil
+}
+
+// LoadDir registers schemas from files named <event type>.v<version>.json in dir in version order
+func (registry *SchemaRegistry) LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	schemas := make([]*Schema, 0)
+
+	for _, file := range files {
+		match := schemaFilePattern.FindStringSubmatch(file.Name())
+
+		if file.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[2])
+		b, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+
+		if err != nil {
+			return err
+		}
+
+		schemas = append(schemas, &Schema{EventType: match[1], Version: version, Definition: string(b)})
+	}
+
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Version < schemas[j].Version
+	})
+
+	for _, schema := range schemas {
+		if existing := registry.Schema(schema.EventType, schema.Version); existing != nil {
+			continue
+		}
+
+		if err = registry.Register(schema); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Register adds new schema version of event type. Version must be higher than the latest one
+// and the schema must be able to read events written with the latest one.
+func (registry *SchemaRegistry) Register(schema *Schema) error {
+	registry.registerLock.Lock()
+	defer registry.registerLock.Unlock()
+
+	if latest := registry.Latest(schema.EventType); latest != nil {
+		if schema.Version <= latest.Version {
+			return fmt.Errorf("schema version %d of %s must be higher than %d", schema.Version, schema.EventType, latest.Version)
+		}
+
+		if err := CheckCompatibility(latest.Definition, schema.Definition); err != nil {
+			return err
+		}
+	}
+
+	registered, err := compileSchema(schema)
+
+	if err != nil {
+		return err
+	}
+
+	if registry.store != nil {
+		if err = registry.store.SaveSchema(schema); err != nil {
+			return err
+		}
+	}
+
+	registry.add(registered)
+
+	return nil
+}
+
+// Schema returns schema of event type version or nil
+func (registry *SchemaRegistry) Schema(eventType string, version int) *Schema {
+	registered := registry.find(eventType, version)
+
+	if registered == nil {
+		return nil
+	}
+
+	return registered.Schema
+}
+
+// Latest returns the highest schema version of event type or nil
+func (registry *SchemaRegistry) Latest(eventType string) *Schema {
+	registered := registry.find(eventType, 0)
+
+	if registered == nil {
+		return nil
+	}
+
+	return registered.Schema
+}
+
+// Validate checks JSON data of event against the schema of its schema version or the latest one when unset.
+// Events without registered schema or with other content type are not validated.
+func (registry *SchemaRegistry) Validate(event *Event) error {
+	if event.ContentType != "" && event.ContentType != ContentTypeJSON {
+		return nil
+	}
+
+	registered := registry.find(event.Type, event.SchemaVersion)
+
+	if registered == nil {
+		if event.SchemaVersion != 0 && registry.find(event.Type, 0) != nil {
+			return &ValidationError{EventType: event.Type, Version: event.SchemaVersion, Errors: []string{"schema version is not registered"}}
+		}
+
+		return nil
+	}
+
+	data := []byte(event.Data)
+
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+
+	result, err := registered.compiled.Validate(gojsonschema.NewBytesLoader(data))
+
+	if err != nil {
+		return &ValidationError{EventType: event.Type, Version: registered.Version, Errors: []string{err.Error()}}
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	validationErr := &ValidationError{EventType: event.Type, Version: registered.Version}
+
+	for _, resultErr := range result.Errors() {
+		validationErr.Errors = append(validationErr.Errors, resultErr.String())
+	}
+
+	return validationErr
+}
+
+// stamp sets schema version of event without one to the latest schema of its type when it would be validated
+func (registry *SchemaRegistry) stamp(event *Event) {
+	if event.SchemaVersion != 0 || (event.ContentType != "" && event.ContentType != ContentTypeJSON) {
+		return
+	}
+
+	if latest := registry.Latest(event.Type); latest != nil {
+		event.SchemaVersion = latest.Version
+	}
+}
+
+func compileSchema(schema *Schema) (*registeredSchema, error) {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema.Definition))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema version %d of %s: %w", schema.Version, schema.EventType, err)
+	}
+
+	return &registeredSchema{Schema: schema, compiled: compiled}, nil
+}
+
+func (registry *SchemaRegistry) add(registered *registeredSchema) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	schemas := append(registry.schemas[registered.EventType], registered)
+
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Version < schemas[j].Version
+	})
+
+	registry.schemas[registered.EventType] = schemas
+}
+
+// find returns schema of event type version. Version 0 means the latest.
+func (registry *SchemaRegistry) find(eventType string, version int) *registeredSchema {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	schemas := registry.schemas[eventType]
+
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	if version == 0 {
+		return schemas[len(schemas)-1]
+	}
+
+	for _, schema := range schemas {
+		if schema.Version == version {
+			return schema
+		}
+	}
+
+	return nil
+}
+
+// CheckCompatibility returns error when data valid for previous schema may not be valid for next schema.
+// It checks top level object properties: next must not require new properties, change property types
+// or forbid properties previous allowed.
+func CheckCompatibility(previous string, next string) error {
+	var previousSchema, nextSchema objectSchema
+
+	if err := json.Unmarshal([]byte(previous), &previousSchema); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal([]byte(next), &nextSchema); err != nil {
+		return err
+	}
+
+	var problems []string
+
+	previousRequired := make(map[string]bool)
+	for _, name := range previousSchema.Required {
+		previousRequired[name] = true
+	}
+
+	for _, name := range nextSchema.Required {
+		if !previousRequired[name] {
+			problems = append(problems, fmt.Sprintf("property %s becomes required", name))
+		}
+	}
+
+	for name, previousProperty := range previousSchema.Properties {
+		nextProperty, ok := nextSchema.Properties[name]
+
+		if !ok {
+			if strings.TrimSpace(string(nextSchema.AdditionalProperties)) == "false" {
+				problems = append(problems, fmt.Sprintf("property %s is removed", name))
+			}
+
+			continue
+		}
+
+		if previousProperty.Type != nil && nextProperty.Type != nil && string(previousProperty.Type) != string(nextProperty.Type) {
+			problems = append(problems, fmt.Sprintf("property %s changes type from %s to %s", name, previousProperty.Type, nextProperty.Type))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("incompatible schema: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// objectSchema represents the part of JSON Schema checked for compatibility
+type objectSchema struct {
+	Required             []string                  `json:"required"`
+	Properties           map[string]propertySchema `json:"properties"`
+	AdditionalProperties json.RawMessage           `json:"additionalProperties"`
+}
+
+type propertySchema struct {
+	Type json.RawMessage `json:"type"`
+}
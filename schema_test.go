@@ -0,0 +1,136 @@
+package dbevent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type memorySchemaStore struct {
+	schemas []*Schema
+}
+
+func (store *memorySchemaStore) SaveSchema(schema *Schema) error {
+	store.schemas = append(store.schemas, schema)
+	return nil
+}
+
+func (store *memorySchemaStore) LoadSchemas() ([]*Schema, error) {
+	return store.schemas, nil
+}
+
+const userCreatedV1 = `{
+	"type": "object",
+	"properties": {"name": {"type": "string"}},
+	"required": ["name"]
+}`
+
+func TestSchemaRegistry_Register(t *testing.T) {
+	store := new(memorySchemaStore)
+	registry, err := NewSchemaRegistry(store)
+	assert.NoError(t, err)
+
+	err = registry.Register(&Schema{EventType: "userCreated", Version: 1, Definition: userCreatedV1})
+	assert.NoError(t, err)
+
+	err = registry.Register(&Schema{EventType: "userCreated", Version: 1, Definition: userCreatedV1})
+	assert.Error(t, err)
+
+	err = registry.Register(&Schema{EventType: "userCreated", Version: 2, Definition: `{
+		"type": "object",
+		"properties": {"name": {"type": "string"}, "age": {"type": "integer"}},
+		"required": ["name"]
+	}`})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, registry.Latest("userCreated").Version)
+	assert.Len(t, store.schemas, 2)
+
+	reloaded, err := NewSchemaRegistry(store)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reloaded.Latest("userCreated").Version)
+	assert.NotNil(t, reloaded.Schema("userCreated", 1))
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	err := CheckCompatibility(userCreatedV1, `{
+		"type": "object",
+		"properties": {"name": {"type": "string"}, "age": {"type": "integer"}},
+		"required": ["name", "age"]
+	}`)
+	assert.EqualError(t, err, "incompatible schema: property age becomes required")
+
+	err = CheckCompatibility(userCreatedV1, `{
+		"type": "object",
+		"properties": {"name": {"type": "integer"}}
+	}`)
+	assert.EqualError(t, err, `incompatible schema: property name changes type from "string" to "integer"`)
+
+	err = CheckCompatibility(userCreatedV1, `{
+		"type": "object",
+		"properties": {"fullName": {"type": "string"}},
+		"additionalProperties": false
+	}`)
+	assert.EqualError(t, err, "incompatible schema: property name is removed")
+}
+
+func TestSchemaRegistry_Validate(t *testing.T) {
+	registry, _ := NewSchemaRegistry(nil)
+	assert.NoError(t, registry.Register(&Schema{EventType: "userCreated", Version: 1, Definition: userCreatedV1}))
+
+	assert.NoError(t, registry.Validate(&Event{Type: "userCreated", Data: JSON(`{"name":"john"}`)}))
+	assert.NoError(t, registry.Validate(&Event{Type: "userDeleted", Data: JSON(`{}`)}))
+	assert.NoError(t, registry.Validate(&Event{Type: "userCreated", ContentType: ContentTypeMessagePack, Data: []byte{0x80}}))
+
+	err := registry.Validate(&Event{Type: "userCreated", Data: JSON(`{"age":1}`)})
+	validationErr, ok := err.(*ValidationError)
+
+	assert.True(t, ok)
+	assert.Equal(t, "userCreated", validationErr.EventType)
+	assert.Equal(t, 1, validationErr.Version)
+	assert.NotEmpty(t, validationErr.Errors)
+}
+
+func TestSchemaRegistry_LoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemas")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "userCreated.v1.json"), []byte(userCreatedV1), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "userCreated.v2.json"), []byte(`{"type": "object", "properties": {"name": {"type": "string"}}}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("schemas"), 0644)
+
+	registry, _ := NewSchemaRegistry(nil)
+
+	assert.NoError(t, registry.LoadDir(dir))
+	assert.Equal(t, 2, registry.Latest("userCreated").Version)
+
+	// already registered versions are skipped
+	assert.NoError(t, registry.LoadDir(dir))
+}
+
+func TestStore_ProduceValidates(t *testing.T) {
+	registry, _ := NewSchemaRegistry(nil)
+	registry.Register(&Schema{EventType: "userCreated", Version: 1, Definition: userCreatedV1})
+
+	mockDriver := new(MockStoreDriver)
+	mockDriver.On("Create", mock.Anything).Return(nil)
+
+	store := newStore(mockDriver).WithSchemaRegistry(registry)
+
+	err := store.Produce(
+		&Event{Type: "userCreated", Data: JSON(`{"name":"john"}`)},
+		&Event{Type: "userCreated", Data: JSON(`{}`)},
+	)
+
+	assert.Error(t, err)
+	mockDriver.AssertNotCalled(t, "Create", mock.Anything)
+
+	err = store.Produce(&Event{Type: "userCreated", Data: JSON(`{"name":"john"}`)})
+
+	assert.NoError(t, err)
+	mockDriver.AssertNumberOfCalls(t, "Create", 1)
+}
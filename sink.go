@@ -0,0 +1,75 @@
+package dbevent
+
+import (
+	"context"
+)
+
+// Sink represents event destination
+type Sink interface {
+	Publish(ctx context.Context, events []*Event) error
+}
+
+// SinkFunc adapts function into Sink
+type SinkFunc func(ctx context.Context, events []*Event) error
+
+// Publish calls sink function
+func (f SinkFunc) Publish(ctx context.Context, events []*Event) error {
+	return f(ctx, events)
+}
+
+// FanOutSink publishes events to every sink in order and fails on the first error
+func FanOutSink(sinks ...Sink) Sink {
+	return SinkFunc(func(ctx context.Context, events []*Event) error {
+		for _, sink := range sinks {
+			if err := sink.Publish(ctx, events); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// FilterSink publishes only events accepted by predicate
+func FilterSink(sink Sink, predicate func(event *Event) bool) Sink {
+	return SinkFunc(func(ctx context.Context, events []*Event) error {
+		filtered := make([]*Event, 0, len(events))
+
+		for _, event := range events {
+			if predicate(event) {
+				filtered = append(filtered, event)
+			}
+		}
+
+		if len(filtered) == 0 {
+			return nil
+		}
+
+		return sink.Publish(ctx, filtered)
+	})
+}
+
+// TransformSink transforms events before publishing. Returning nil event drops it.
+func TransformSink(sink Sink, transform func(event *Event) (*Event, error)) Sink {
+	return SinkFunc(func(ctx context.Context, events []*Event) error {
+		transformed := make([]*Event, 0, len(events))
+
+		for _, event := range events {
+			result, err := transform(event)
+
+			if err != nil {
+				return err
+			}
+
+			if result != nil {
+				transformed = append(transformed, result)
+			}
+		}
+
+		if len(transformed) == 0 {
+			return nil
+		}
+
+		return sink.Publish(ctx, transformed)
+	})
+}
@@ -0,0 +1,86 @@
+package dbevent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordSink struct {
+	published [][]*Event
+	err       error
+}
+
+func (sink *recordSink) Publish(ctx context.Context, events []*Event) error {
+	if sink.err != nil {
+		return sink.err
+	}
+
+	sink.published = append(sink.published, events)
+	return nil
+}
+
+func TestFanOutSink(t *testing.T) {
+	sink1 := &recordSink{}
+	sink2 := &recordSink{}
+
+	events := []*Event{{ID: 1}, {ID: 2}}
+
+	err := FanOutSink(sink1, sink2).Publish(context.Background(), events)
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]*Event{events}, sink1.published)
+	assert.Equal(t, [][]*Event{events}, sink2.published)
+}
+
+func TestFanOutSink_Error(t *testing.T) {
+	mockErr := errors.New("mock error")
+	sink1 := &recordSink{err: mockErr}
+	sink2 := &recordSink{}
+
+	err := FanOutSink(sink1, sink2).Publish(context.Background(), []*Event{{ID: 1}})
+
+	assert.Equal(t, mockErr, err)
+	assert.Empty(t, sink2.published)
+}
+
+func TestFilterSink(t *testing.T) {
+	sink := &recordSink{}
+
+	filter := FilterSink(sink, func(event *Event) bool {
+		return event.Type == "testtype"
+	})
+
+	err := filter.Publish(context.Background(), []*Event{{ID: 1, Type: "testtype"}, {ID: 2, Type: "othertype"}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, sink.published, 1) {
+		assert.Equal(t, []*Event{{ID: 1, Type: "testtype"}}, sink.published[0])
+	}
+
+	err = filter.Publish(context.Background(), []*Event{{ID: 3, Type: "othertype"}})
+
+	assert.NoError(t, err)
+	assert.Len(t, sink.published, 1)
+}
+
+func TestTransformSink(t *testing.T) {
+	sink := &recordSink{}
+
+	transform := TransformSink(sink, func(event *Event) (*Event, error) {
+		if event.ID == 2 {
+			return nil, nil
+		}
+
+		return &Event{ID: event.ID, Type: "transformed"}, nil
+	})
+
+	err := transform.Publish(context.Background(), []*Event{{ID: 1}, {ID: 2}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, sink.published, 1) {
+		assert.Equal(t, []*Event{{ID: 1, Type: "transformed"}}, sink.published[0])
+	}
+}
@@ -1,5 +1,9 @@
 package dbevent
 
+import (
+	"fmt"
+)
+
 // StoreDriver represents event store driver
 type StoreDriver interface {
 	Provision() error
@@ -10,7 +14,13 @@ type StoreDriver interface {
 
 // Store represents event store
 type Store struct {
-	driver StoreDriver
+	// base is the driver given to NewStore and driver wraps it with tenant, encryptor and upcasters
+	base      StoreDriver
+	driver    StoreDriver
+	tenantID  string
+	encryptor Encryptor
+	upcasters *Upcasters
+	schemas   *SchemaRegistry
 }
 
 // NewStore creates new store
@@ -19,21 +29,151 @@ func NewStore(driver StoreDriver) *Store {
 		panic(err)
 	}
 
+	return newStore(driver)
+}
+
+func newStore(driver StoreDriver) *Store {
 	return &Store{
+		base:   driver,
 		driver: driver,
 	}
 }
 
-// Produce creates new event
+// wrap builds driver chain in fixed order whatever order options were applied in:
+// tenant scope first, then encryption, then upcasting so upcasters always see plaintext
+func (store *Store) wrap() {
+	driver := store.base
+
+	if store.tenantID != "" {
+		driver = newTenantDriver(driver, store.tenantID)
+	}
+
+	if store.encryptor != nil {
+		driver = &encryptingDriver{StoreDriver: driver, encryptor: store.encryptor}
+	}
+
+	if store.upcasters != nil {
+		driver = &upcastingDriver{StoreDriver: driver, upcasters: store.upcasters}
+	}
+
+	store.driver = driver
+}
+
+// Produce creates new event. It fails without creating any event when one does not match its registered schema.
+// Events without schema version get the version they are validated against.
 func (store *Store) Produce(events ...*Event) error {
+	if store.schemas != nil {
+		for _, event := range events {
+			store.schemas.stamp(event)
+
+			if err := store.schemas.Validate(event); err != nil {
+				return err
+			}
+		}
+	}
+
 	return store.driver.Create(events...)
 }
 
+// ForTenant returns store scoped to tenant. Produced events belong to the tenant and
+// consumers only receive its events, with offsets and locks tracked per tenant.
+func (store *Store) ForTenant(tenantID string) *Store {
+	scoped := *store
+	scoped.tenantID = tenantID
+	scoped.wrap()
+
+	return &scoped
+}
+
+// WithEncryptor returns store encrypting event data on produce and decrypting it when consumed or loaded
+func (store *Store) WithEncryptor(encryptor Encryptor) *Store {
+	encrypted := *store
+	encrypted.encryptor = encryptor
+	encrypted.wrap()
+
+	return &encrypted
+}
+
+// WithUpcasters returns store upcasting events to the latest version of their type when consumed or loaded.
+// Produced events without schema version get the latest version.
+func (store *Store) WithUpcasters(upcasters *Upcasters) *Store {
+	upcasting := *store
+	upcasting.upcasters = upcasters
+	upcasting.wrap()
+
+	return &upcasting
+}
+
+// WithSchemaRegistry returns store validating produced events against schemas of registry
+func (store *Store) WithSchemaRegistry(schemas *SchemaRegistry) *Store {
+	validated := *store
+	validated.schemas = schemas
+
+	return &validated
+}
+
+// LoadStream returns events of aggregate in order
+func (store *Store) LoadStream(aggregateType string, aggregateID string) ([]*Event, error) {
+	return loadStream(store.driver, aggregateType, aggregateID)
+}
+
+// ReEncrypt rewrites every stored event not encrypted with the active key of store encryptor.
+// It returns number of rewritten events.
+func (store *Store) ReEncrypt(batchSize int) (int, error) {
+	encrypting, err := store.encryptingDriver()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return encrypting.reEncrypt(batchSize)
+}
+
+// ForgetSubject deletes keys of subject so data of its events becomes unreadable.
+// Store encryptor must be SubjectEncryptor.
+func (store *Store) ForgetSubject(subjectID string) error {
+	encrypting, err := store.encryptingDriver()
+
+	if err != nil {
+		return err
+	}
+
+	forgetter, ok := encrypting.encryptor.(subjectForgetter)
+
+	if !ok {
+		return fmt.Errorf("store encryptor cannot forget subject")
+	}
+
+	return forgetter.ForgetSubject(subjectID)
+}
+
+// encryptingDriver returns encryptor over the unscoped driver so maintenance covers every tenant
+func (store *Store) encryptingDriver() (*encryptingDriver, error) {
+	if store.encryptor == nil {
+		return nil, fmt.Errorf("store has no encryptor")
+	}
+
+	return &encryptingDriver{StoreDriver: store.base, encryptor: store.encryptor}, nil
+}
+
 // NewConsumer creates new consumer for store
 func (store *Store) NewConsumer(readGroup string, config *ConsumerConfig) *Consumer {
 	return NewConsumer(readGroup, store.driver, config)
 }
 
+// NewRelay creates new relay publishing store events to sinks
+func (store *Store) NewRelay(config *ConsumerConfig) *Relay {
+	return NewRelay(store.driver, config)
+}
+
+// NewWebhookDispatcher creates new webhook dispatcher consuming store events as read group.
+// Delivery attempts are recorded when store driver is DeliveryRecorder.
+func (store *Store) NewWebhookDispatcher(readGroup string, consumerConfig *ConsumerConfig, config *WebhookConfig) *WebhookDispatcher {
+	recorder, _ := store.base.(DeliveryRecorder)
+
+	return NewWebhookDispatcher(store.NewConsumer(readGroup, consumerConfig), recorder, config)
+}
+
 // Close driver
 func (store *Store) Close() error {
 	return store.driver.Close()
@@ -0,0 +1,174 @@
+package dbevent
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// MetadataSubjectID is metadata key of the subject whose key encrypted the event
+	MetadataSubjectID = "encryption_subject_id"
+	// MetadataSubjectKeyID is metadata key of the id of subject key
+	MetadataSubjectKeyID = "encryption_subject_key_id"
+)
+
+// SubjectKeyStore represents storage of per subject data keys
+type SubjectKeyStore interface {
+	// SubjectKey returns current key of subject, creating one when absent
+	SubjectKey(subjectID string) (keyID string, key []byte, err error)
+	// LookupSubjectKey returns key by id. Key is nil when it was forgotten.
+	LookupSubjectKey(subjectID string, keyID string) ([]byte, error)
+	// ForgetSubject deletes every key of subject
+	ForgetSubject(subjectID string) error
+}
+
+// SubjectEncryptor represents encryption with a key per subject. Forgetting the subject deletes
+// its keys so data of its events can never be read again and they are delivered as redacted.
+type SubjectEncryptor struct {
+	keys SubjectKeyStore
+	// subjectOf returns subject of event. Empty subject leaves event unencrypted.
+	subjectOf func(event *Event) string
+}
+
+// NewSubjectEncryptor creates new instance. Subject of event is its aggregate id when subjectOf is nil.
+func NewSubjectEncryptor(keys SubjectKeyStore, subjectOf func(event *Event) string) *SubjectEncryptor {
+	if subjectOf == nil {
+		subjectOf = func(event *Event) string {
+			return event.AggregateID
+		}
+	}
+
+	return &SubjectEncryptor{
+		keys:      keys,
+		subjectOf: subjectOf,
+	}
+}
+
+// Encrypt replaces data with data encrypted by subject key
+func (encryptor *SubjectEncryptor) Encrypt(event *Event) error {
+	subjectID := encryptor.subjectOf(event)
+
+	if len(event.Data) == 0 || subjectID == "" {
+		return nil
+	}
+
+	if _, ok := event.Metadata[MetadataSubjectKeyID]; ok {
+		return fmt.Errorf("event already encrypted")
+	}
+
+	keyID, key, err := encryptor.keys.SubjectKey(subjectID)
+
+	if err != nil {
+		return err
+	}
+
+	aead, err := newGCM(key)
+
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := seal(aead, event.Data, associatedData(event))
+
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(base64.StdEncoding.EncodeToString(encrypted))
+
+	if err != nil {
+		return err
+	}
+
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string)
+	}
+
+	event.Data = data
+	event.Metadata[MetadataSubjectID] = subjectID
+	event.Metadata[MetadataSubjectKeyID] = keyID
+
+	return nil
+}
+
+// Decrypt restores data. Event of forgotten subject is marked redacted with no data.
+func (encryptor *SubjectEncryptor) Decrypt(event *Event) error {
+	keyID, ok := event.Metadata[MetadataSubjectKeyID]
+
+	if !ok {
+		return nil
+	}
+
+	subjectID := event.Metadata[MetadataSubjectID]
+	key, err := encryptor.keys.LookupSubjectKey(subjectID, keyID)
+
+	if err != nil {
+		return err
+	}
+
+	delete(event.Metadata, MetadataSubjectKeyID)
+
+	if key == nil {
+		event.Data = nil
+		event.Redacted = true
+		return nil
+	}
+
+	delete(event.Metadata, MetadataSubjectID)
+
+	aead, err := newGCM(key)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := openData(aead, event)
+
+	if err != nil {
+		return err
+	}
+
+	event.Data = data
+
+	return nil
+}
+
+// Rotated returns true when event data is not encrypted with a subject key
+func (encryptor *SubjectEncryptor) Rotated(event *Event) bool {
+	if len(event.Data) == 0 || event.Redacted {
+		return false
+	}
+
+	_, ok := event.Metadata[MetadataSubjectKeyID]
+
+	return !ok && encryptor.subjectOf(event) != ""
+}
+
+// ForgetSubject deletes keys of subject
+func (encryptor *SubjectEncryptor) ForgetSubject(subjectID string) error {
+	return encryptor.keys.ForgetSubject(subjectID)
+}
+
+// openData decrypts data encoded by Encrypt
+func openData(aead cipher.AEAD, event *Event) ([]byte, error) {
+	var encoded string
+
+	if err := json.Unmarshal(event.Data, &encoded); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return open(aead, encrypted, associatedData(event))
+}
+
+// subjectForgetter is implemented by encryptors able to forget subject
+type subjectForgetter interface {
+	ForgetSubject(subjectID string) error
+}
@@ -0,0 +1,95 @@
+package dbevent
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memorySubjectKeys struct {
+	keys map[string]map[string][]byte
+}
+
+func newMemorySubjectKeys() *memorySubjectKeys {
+	return &memorySubjectKeys{keys: make(map[string]map[string][]byte)}
+}
+
+func (store *memorySubjectKeys) SubjectKey(subjectID string) (string, []byte, error) {
+	for keyID, key := range store.keys[subjectID] {
+		return keyID, key, nil
+	}
+
+	keyID := fmt.Sprintf("%s-key", subjectID)
+	key := bytes.Repeat([]byte{byte(len(store.keys) + 1)}, 32)
+	store.keys[subjectID] = map[string][]byte{keyID: key}
+
+	return keyID, key, nil
+}
+
+func (store *memorySubjectKeys) LookupSubjectKey(subjectID string, keyID string) ([]byte, error) {
+	return store.keys[subjectID][keyID], nil
+}
+
+func (store *memorySubjectKeys) ForgetSubject(subjectID string) error {
+	delete(store.keys, subjectID)
+	return nil
+}
+
+func TestSubjectEncryptor_EncryptDecrypt(t *testing.T) {
+	encryptor := NewSubjectEncryptor(newMemorySubjectKeys(), nil)
+
+	event := &Event{AggregateType: "user", AggregateID: "user1", Data: JSON(`{"name":"john"}`)}
+
+	err := encryptor.Encrypt(event)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(event.Data), "john")
+	assert.Equal(t, "user1", event.Metadata[MetadataSubjectID])
+	assert.False(t, encryptor.Rotated(event))
+
+	err = encryptor.Decrypt(event)
+
+	assert.NoError(t, err)
+	assert.False(t, event.Redacted)
+	assert.Equal(t, JSON(`{"name":"john"}`), event.Data)
+	assert.Empty(t, event.Metadata)
+}
+
+func TestSubjectEncryptor_Forget(t *testing.T) {
+	encryptor := NewSubjectEncryptor(newMemorySubjectKeys(), nil)
+
+	event1 := &Event{AggregateType: "user", AggregateID: "user1", Data: JSON(`{"name":"john"}`)}
+	event2 := &Event{AggregateType: "user", AggregateID: "user2", Data: JSON(`{"name":"jane"}`)}
+	assert.NoError(t, encryptor.Encrypt(event1))
+	assert.NoError(t, encryptor.Encrypt(event2))
+
+	assert.NoError(t, encryptor.ForgetSubject("user1"))
+
+	assert.NoError(t, encryptor.Decrypt(event1))
+	assert.True(t, event1.Redacted)
+	assert.Nil(t, event1.Data)
+	assert.Equal(t, "user1", event1.Metadata[MetadataSubjectID])
+
+	assert.NoError(t, encryptor.Decrypt(event2))
+	assert.False(t, event2.Redacted)
+	assert.Equal(t, JSON(`{"name":"jane"}`), event2.Data)
+}
+
+func TestStore_ForgetSubject(t *testing.T) {
+	keys := newMemorySubjectKeys()
+	keys.SubjectKey("user1")
+
+	store := newStore(new(MockStoreDriver)).WithEncryptor(NewSubjectEncryptor(keys, nil))
+
+	err := store.ForgetSubject("user1")
+
+	assert.NoError(t, err)
+	assert.Empty(t, keys.keys)
+
+	aesEncryptor, _ := NewAESEncryptor("key1", map[string][]byte{"key1": testKey1})
+	store = newStore(new(MockStoreDriver)).WithEncryptor(aesEncryptor)
+
+	assert.Error(t, store.ForgetSubject("user1"))
+}
@@ -0,0 +1,147 @@
+package dbevent
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrTenantMismatch is returned when event belongs to another tenant
+	ErrTenantMismatch = errors.New("event belongs to another tenant")
+	// ErrInvalidReadGroup is returned when read group contains tenant separator
+	ErrInvalidReadGroup = errors.New("read group must not contain " + tenantSeparator)
+)
+
+// tenantSeparator separates read group and tenant. Read group must not contain it.
+const tenantSeparator = "@"
+
+// ValidateReadGroup returns error when read group cannot be told apart from a tenant read group
+func ValidateReadGroup(readGroup string) error {
+	if strings.Contains(readGroup, tenantSeparator) {
+		return ErrInvalidReadGroup
+	}
+
+	return nil
+}
+
+// TenantReadGroup returns read group consuming only events of tenant
+func TenantReadGroup(readGroup string, tenantID string) string {
+	if tenantID == "" {
+		return readGroup
+	}
+
+	return readGroup + tenantSeparator + tenantID
+}
+
+// ParseTenantReadGroup splits tenant read group into read group and tenant. Tenant is empty for every tenant.
+func ParseTenantReadGroup(name string) (string, string) {
+	i := strings.Index(name, tenantSeparator)
+
+	if i < 0 {
+		return name, ""
+	}
+
+	return name[:i], name[i+len(tenantSeparator):]
+}
+
+// tenantDriver represents store driver scoped to tenant
+type tenantDriver struct {
+	StoreDriver
+	tenantID string
+}
+
+func newTenantDriver(driver StoreDriver, tenantID string) *tenantDriver {
+	if scoped, ok := driver.(*tenantDriver); ok {
+		driver = scoped.StoreDriver
+	}
+
+	return &tenantDriver{StoreDriver: driver, tenantID: tenantID}
+}
+
+// Create assigns tenant to events. It fails when an event belongs to another tenant.
+func (driver *tenantDriver) Create(events ...*Event) error {
+	for _, event := range events {
+		if event.TenantID == "" {
+			event.TenantID = driver.tenantID
+		}
+
+		if event.TenantID != driver.tenantID {
+			return ErrTenantMismatch
+		}
+	}
+
+	return driver.StoreDriver.Create(events...)
+}
+
+// Fetch fetches events of tenant. It fails rather than return events of another tenant.
+func (driver *tenantDriver) Fetch(readGroup string, limit int) ([]*Event, error) {
+	events, err := driver.StoreDriver.Fetch(driver.readGroup(readGroup), limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = driver.guard(events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// CommitInTrans commits event of tenant as processed
+func (driver *tenantDriver) CommitInTrans(readGroup string, event *Event, handler func() error) error {
+	if err := driver.guard([]*Event{event}); err != nil {
+		return err
+	}
+
+	return driver.StoreDriver.CommitInTrans(driver.readGroup(readGroup), event, handler)
+}
+
+// CommitBatchInTrans commits events of tenant as processed
+func (driver *tenantDriver) CommitBatchInTrans(readGroup string, events []*Event, handler func() error) error {
+	if err := driver.guard(events); err != nil {
+		return err
+	}
+
+	return driver.StoreDriver.CommitBatchInTrans(driver.readGroup(readGroup), events, handler)
+}
+
+// WatchPartitions forwards to driver when it supports partitions
+func (driver *tenantDriver) WatchPartitions(readGroup string, onAssign func(partitions []int), onRevoke func(partitions []int)) {
+	if watcher, ok := driver.StoreDriver.(PartitionWatcher); ok {
+		watcher.WatchPartitions(driver.readGroup(readGroup), onAssign, onRevoke)
+	}
+}
+
+// LoadStream loads events of aggregate belonging to tenant
+func (driver *tenantDriver) LoadStream(aggregateType string, aggregateID string) ([]*Event, error) {
+	events, err := loadStream(driver.StoreDriver, aggregateType, aggregateID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make([]*Event, 0, len(events))
+
+	for _, event := range events {
+		if event.TenantID == driver.tenantID {
+			scoped = append(scoped, event)
+		}
+	}
+
+	return scoped, nil
+}
+
+func (driver *tenantDriver) readGroup(readGroup string) string {
+	return TenantReadGroup(readGroup, driver.tenantID)
+}
+
+func (driver *tenantDriver) guard(events []*Event) error {
+	for _, event := range events {
+		if event.TenantID != driver.tenantID {
+			return ErrTenantMismatch
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package dbevent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTenantReadGroup(t *testing.T) {
+	assert.Equal(t, "group1", TenantReadGroup("group1", ""))
+	assert.Equal(t, "group1@tenant1", TenantReadGroup("group1", "tenant1"))
+
+	readGroup, tenantID := ParseTenantReadGroup("group1@tenant1")
+	assert.Equal(t, "group1", readGroup)
+	assert.Equal(t, "tenant1", tenantID)
+
+	readGroup, tenantID = ParseTenantReadGroup("group1")
+	assert.Equal(t, "group1", readGroup)
+	assert.Equal(t, "", tenantID)
+}
+
+func TestTenantDriver_Create(t *testing.T) {
+	mockDriver := new(MockStoreDriver)
+	mockDriver.On("Create", mock.Anything).Return(nil)
+
+	driver := newTenantDriver(mockDriver, "tenant1")
+	event := &Event{Type: "testtype"}
+
+	err := driver.Create(event)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant1", event.TenantID)
+	mockDriver.AssertCalled(t, "Create", event)
+
+	err = driver.Create(&Event{Type: "testtype", TenantID: "tenant2"})
+
+	assert.Equal(t, ErrTenantMismatch, err)
+	mockDriver.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestTenantDriver_Fetch(t *testing.T) {
+	mockDriver := new(MockStoreDriver)
+	events := []*Event{{ID: 1, TenantID: "tenant1"}}
+	mockDriver.On("Fetch", "group1@tenant1", 10).Return(events, nil)
+
+	driver := newTenantDriver(mockDriver, "tenant1")
+
+	fetched, err := driver.Fetch("group1", 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, events, fetched)
+}
+
+func TestTenantDriver_FetchGuard(t *testing.T) {
+	mockDriver := new(MockStoreDriver)
+	mockDriver.On("Fetch", "group1@tenant1", 10).Return([]*Event{{ID: 1, TenantID: "tenant2"}}, nil)
+
+	driver := newTenantDriver(mockDriver, "tenant1")
+
+	fetched, err := driver.Fetch("group1", 10)
+
+	assert.Equal(t, ErrTenantMismatch, err)
+	assert.Nil(t, fetched)
+}
+
+func TestTenantDriver_CommitBatchInTrans(t *testing.T) {
+	mockDriver := new(MockStoreDriver)
+	events := []*Event{{ID: 1, TenantID: "tenant1"}}
+	mockDriver.On("CommitBatchInTrans", "group1@tenant1", events, mock.Anything).Return(nil)
+
+	driver := newTenantDriver(mockDriver, "tenant1")
+
+	err := driver.CommitBatchInTrans("group1", events, func() error { return nil })
+
+	assert.NoError(t, err)
+	mockDriver.AssertExpectations(t)
+}
+
+func TestValidateReadGroup(t *testing.T) {
+	assert.NoError(t, ValidateReadGroup("orders"))
+	assert.Equal(t, ErrInvalidReadGroup, ValidateReadGroup("orders@eu"))
+
+	assert.Panics(t, func() {
+		NewConsumer("orders@eu", new(MockConsumerDriver), &ConsumerConfig{})
+	})
+}
@@ -34,12 +34,24 @@ func (j JSON) Value() (driver.Value, error) {
 
 // Event represents event data
 type Event struct {
-	ID            uint   `xorm:"pk 'id'"`
+	ID uint `xorm:"pk 'id'"`
+	// EventID is unique id given by producer
+	EventID       string `xorm:"event_id"`
 	Type          string `xorm:"type" gorm:"not null"`
 	AggregateType string `xorm:"aggregate_type"`
 	AggregateID   string `xorm:"aggregate_id"`
-	Data          JSON
-	CreatedAt     *time.Time `xorm:"created_at"`
+	TenantID      string `xorm:"tenant_id"`
+	// Data is encoded by codec of ContentType. It is named JSON for compatibility but may hold any bytes.
+	Data JSON
+	// ContentType selects codec decoding Data. Empty means JSON.
+	ContentType string `xorm:"content_type"`
+	// SchemaVersion is version of Data shape. 0 means version 1.
+	SchemaVersion int `xorm:"schema_version"`
+	// Metadata carries attributes about the event such as encryption key id
+	Metadata  map[string]string `xorm:"metadata"`
+	CreatedAt *time.Time        `xorm:"created_at"`
+	// Redacted is true when data was erased because its subject was forgotten
+	Redacted bool `xorm:"-"`
 }
 
 // DBConfig represents database configuration
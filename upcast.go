@@ -0,0 +1,173 @@
+package dbevent
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Upcaster transforms data of an event type version into data of the next version
+type Upcaster func(data []byte) ([]byte, error)
+
+// Upcasters represents upcaster chains by event type. Events without schema version are version 1.
+type Upcasters struct {
+	lock      sync.RWMutex
+	upcasters map[string]map[int]Upcaster
+}
+
+// NewUpcasters creates empty upcaster chains
+func NewUpcasters() *Upcasters {
+	return &Upcasters{
+		upcasters: make(map[string]map[int]Upcaster),
+	}
+}
+
+// Register adds upcaster transforming event type data from version to version + 1
+func (upcasters *Upcasters) Register(eventType string, fromVersion int, upcaster Upcaster) error {
+	if fromVersion < 1 {
+		return fmt.Errorf("upcaster version of %s must be at least 1", eventType)
+	}
+
+	upcasters.lock.Lock()
+	defer upcasters.lock.Unlock()
+
+	chain, ok := upcasters.upcasters[eventType]
+
+	if !ok {
+		chain = make(map[int]Upcaster)
+		upcasters.upcasters[eventType] = chain
+	}
+
+	if _, ok = chain[fromVersion]; ok {
+		return fmt.Errorf("upcaster of %s from version %d already registered", eventType, fromVersion)
+	}
+
+	chain[fromVersion] = upcaster
+
+	return nil
+}
+
+// Latest returns version of event type reached by upcasting from version 1
+func (upcasters *Upcasters) Latest(eventType string) int {
+	upcasters.lock.RLock()
+	defer upcasters.lock.RUnlock()
+
+	version := 1
+	chain := upcasters.upcasters[eventType]
+
+	for chain[version] != nil {
+		version++
+	}
+
+	return version
+}
+
+// Upcast applies upcasters of event type from event schema version until no next one is registered
+func (upcasters *Upcasters) Upcast(event *Event) error {
+	upcasters.lock.RLock()
+	defer upcasters.lock.RUnlock()
+
+	chain := upcasters.upcasters[event.Type]
+
+	if event.SchemaVersion == 0 {
+		event.SchemaVersion = 1
+	}
+
+	// redacted events have no data to transform
+	if event.Redacted {
+		return nil
+	}
+
+	for upcaster := chain[event.SchemaVersion]; upcaster != nil; upcaster = chain[event.SchemaVersion] {
+		data, err := upcaster(event.Data)
+
+		if err != nil {
+			return fmt.Errorf("cannot upcast event %d from version %d: %w", event.ID, event.SchemaVersion, err)
+		}
+
+		event.Data = data
+		event.SchemaVersion++
+	}
+
+	return nil
+}
+
+func (upcasters *Upcasters) upcast(events []*Event) error {
+	for _, event := range events {
+		if err := upcasters.Upcast(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upcastingDriver represents store driver stamping created events with the latest version
+// and upcasting events fetched or loaded
+type upcastingDriver struct {
+	StoreDriver
+	upcasters *Upcasters
+}
+
+// Create stamps events without schema version with the latest version of their type
+func (driver *upcastingDriver) Create(events ...*Event) error {
+	for _, event := range events {
+		if event.SchemaVersion == 0 {
+			event.SchemaVersion = driver.upcasters.Latest(event.Type)
+		}
+	}
+
+	return driver.StoreDriver.Create(events...)
+}
+
+// Fetch fetches and upcasts events
+func (driver *upcastingDriver) Fetch(readGroup string, limit int) ([]*Event, error) {
+	events, err := driver.StoreDriver.Fetch(readGroup, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, driver.upcasters.upcast(events)
+}
+
+// LoadStream loads and upcasts events of aggregate
+func (driver *upcastingDriver) LoadStream(aggregateType string, aggregateID string) ([]*Event, error) {
+	events, err := loadStream(driver.StoreDriver, aggregateType, aggregateID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, driver.upcasters.upcast(events)
+}
+
+// WatchPartitions forwards to driver when it supports partitions
+func (driver *upcastingDriver) WatchPartitions(readGroup string, onAssign func(partitions []int), onRevoke func(partitions []int)) {
+	if watcher, ok := driver.StoreDriver.(PartitionWatcher); ok {
+		watcher.WatchPartitions(readGroup, onAssign, onRevoke)
+	}
+}
+
+// upcastingConsumerDriver represents consumer driver upcasting fetched events
+type upcastingConsumerDriver struct {
+	ConsumerDriver
+	upcasters *Upcasters
+}
+
+// Fetch fetches and upcasts events
+func (driver *upcastingConsumerDriver) Fetch(readGroup string, limit int) ([]*Event, error) {
+	events, err := driver.ConsumerDriver.Fetch(readGroup, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, driver.upcasters.upcast(events)
+}
+
+// WatchPartitions forwards to driver when it supports partitions
+func (driver *upcastingConsumerDriver) WatchPartitions(readGroup string, onAssign func(partitions []int), onRevoke func(partitions []int)) {
+	if watcher, ok := driver.ConsumerDriver.(PartitionWatcher); ok {
+		watcher.WatchPartitions(readGroup, onAssign, onRevoke)
+	}
+}
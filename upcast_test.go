@@ -0,0 +1,178 @@
+package dbevent
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func orderCreatedUpcasters() *Upcasters {
+	upcasters := NewUpcasters()
+
+	// v1 {"amount": 10} -> v2 {"total": 10}
+	upcasters.Register("orderCreated", 1, func(data []byte) ([]byte, error) {
+		var v1 struct{ Amount int }
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(map[string]interface{}{"total": v1.Amount})
+	})
+
+	// v2 {"total": 10} -> v3 {"total": 10, "currency": "THB"}
+	upcasters.Register("orderCreated", 2, func(data []byte) ([]byte, error) {
+		v2 := make(map[string]interface{})
+		if err := json.Unmarshal(data, &v2); err != nil {
+			return nil, err
+		}
+
+		v2["currency"] = "THB"
+		return json.Marshal(v2)
+	})
+
+	return upcasters
+}
+
+func TestUpcasters_Upcast(t *testing.T) {
+	upcasters := orderCreatedUpcasters()
+
+	assert.Equal(t, 3, upcasters.Latest("orderCreated"))
+	assert.Equal(t, 1, upcasters.Latest("orderCancelled"))
+	assert.Error(t, upcasters.Register("orderCreated", 1, nil))
+
+	event := &Event{Type: "orderCreated", Data: JSON(`{"amount":10}`)}
+
+	assert.NoError(t, upcasters.Upcast(event))
+	assert.Equal(t, 3, event.SchemaVersion)
+	assert.JSONEq(t, `{"total":10,"currency":"THB"}`, string(event.Data))
+
+	event = &Event{Type: "orderCreated", SchemaVersion: 2, Data: JSON(`{"total":5}`)}
+
+	assert.NoError(t, upcasters.Upcast(event))
+	assert.Equal(t, 3, event.SchemaVersion)
+	assert.JSONEq(t, `{"total":5,"currency":"THB"}`, string(event.Data))
+
+	event = &Event{Type: "orderCancelled", Data: JSON(`{}`)}
+
+	assert.NoError(t, upcasters.Upcast(event))
+	assert.Equal(t, 1, event.SchemaVersion)
+}
+
+func TestUpcasters_UpcastError(t *testing.T) {
+	upcasters := NewUpcasters()
+	upcasters.Register("orderCreated", 1, func(data []byte) ([]byte, error) {
+		return nil, errors.New("bad data")
+	})
+
+	err := upcasters.Upcast(&Event{ID: 1, Type: "orderCreated"})
+
+	assert.EqualError(t, err, "cannot upcast event 1 from version 1: bad data")
+}
+
+func TestStore_WithUpcasters(t *testing.T) {
+	mockDriver := new(MockStoreDriver)
+	mockDriver.On("Create", mock.Anything).Return(nil)
+	mockDriver.On("Fetch", "group1", 10).Return([]*Event{
+		{ID: 1, Type: "orderCreated", SchemaVersion: 1, Data: JSON(`{"amount":10}`)},
+		{ID: 2, Type: "orderCreated", SchemaVersion: 3, Data: JSON(`{"total":20,"currency":"USD"}`)},
+	}, nil)
+
+	store := newStore(mockDriver).WithUpcasters(orderCreatedUpcasters())
+
+	event := &Event{Type: "orderCreated", Data: JSON(`{"total":10,"currency":"THB"}`)}
+	assert.NoError(t, store.Produce(event))
+	assert.Equal(t, 3, event.SchemaVersion)
+
+	events, err := store.driver.Fetch("group1", 10)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total":10,"currency":"THB"}`, string(events[0].Data))
+	assert.JSONEq(t, `{"total":20,"currency":"USD"}`, string(events[1].Data))
+	assert.Equal(t, 3, events[0].SchemaVersion)
+}
+
+func TestStore_WithUpcastersDecryptsFirst(t *testing.T) {
+	encryptor, _ := NewAESEncryptor("key1", map[string][]byte{"key1": testKey1})
+	encrypted := &Event{ID: 1, Type: "orderCreated", Data: JSON(`{"amount":10}`)}
+	assert.NoError(t, encryptor.Encrypt(encrypted))
+
+	mockDriver := new(MockStoreDriver)
+	mockDriver.On("Fetch", "group1", 10).Return([]*Event{encrypted}, nil)
+
+	store := newStore(mockDriver).WithUpcasters(orderCreatedUpcasters()).WithEncryptor(encryptor)
+
+	events, err := store.driver.Fetch("group1", 10)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total":10,"currency":"THB"}`, string(events[0].Data))
+}
+
+func TestStore_WithUpcastersAnyOrder(t *testing.T) {
+	encryptor, _ := NewAESEncryptor("key1", map[string][]byte{"key1": testKey1})
+	encrypted := &Event{ID: 1, Type: "orderCreated", TenantID: "tenant1", Data: JSON(`{"amount":10}`)}
+	assert.NoError(t, encryptor.Encrypt(encrypted))
+
+	mockDriver := new(MockStoreDriver)
+	mockDriver.On("Fetch", "group1@tenant1", 10).Return([]*Event{encrypted}, nil)
+
+	store := newStore(mockDriver).WithUpcasters(orderCreatedUpcasters()).ForTenant("tenant1").WithEncryptor(encryptor)
+
+	events, err := store.driver.Fetch("group1", 10)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total":10,"currency":"THB"}`, string(events[0].Data))
+
+	_, err = store.encryptingDriver()
+	assert.NoError(t, err)
+}
+
+func TestConsumer_Upcasters(t *testing.T) {
+	mockDriver := new(MockConsumerDriver)
+	mockDriver.On("Fetch", "group1", 10).Return([]*Event{
+		{ID: 1, Type: "orderCreated", Data: JSON(`{"amount":10}`)},
+	}, nil)
+
+	consumer := NewConsumer("group1", mockDriver, &ConsumerConfig{Upcasters: orderCreatedUpcasters()})
+
+	events, err := consumer.driver.Fetch("group1", 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, events[0].SchemaVersion)
+}
+
+func TestSchemaRegistry_ValidateSchemaVersion(t *testing.T) {
+	registry, _ := NewSchemaRegistry(nil)
+	registry.Register(&Schema{EventType: "userCreated", Version: 1, Definition: userCreatedV1})
+	registry.Register(&Schema{EventType: "userCreated", Version: 2, Definition: `{"type": "object"}`})
+
+	assert.NoError(t, registry.Validate(&Event{Type: "userCreated", Data: JSON(`{}`)}))
+	assert.Error(t, registry.Validate(&Event{Type: "userCreated", SchemaVersion: 1, Data: JSON(`{}`)}))
+	assert.Error(t, registry.Validate(&Event{Type: "userCreated", SchemaVersion: 3, Data: JSON(`{}`)}))
+}
+
+func TestStore_ProduceStampsValidatedVersion(t *testing.T) {
+	registry, _ := NewSchemaRegistry(nil)
+	registry.Register(&Schema{EventType: "orderCreated", Version: 1, Definition: `{"type": "object", "properties": {"amount": {"type": "integer"}}}`})
+	registry.Register(&Schema{EventType: "orderCreated", Version: 2, Definition: `{"type": "object", "properties": {"total": {"type": "integer"}}}`})
+	registry.Register(&Schema{EventType: "orderCreated", Version: 3, Definition: `{"type": "object", "properties": {"total": {"type": "integer"}, "currency": {"type": "string"}}}`})
+
+	mockDriver := new(MockStoreDriver)
+	mockDriver.On("Create", mock.Anything).Return(nil)
+
+	// produced before upcasters are registered
+	event := &Event{Type: "orderCreated", Data: JSON(`{"total":10,"currency":"USD"}`)}
+	assert.NoError(t, newStore(mockDriver).WithSchemaRegistry(registry).Produce(event))
+	assert.Equal(t, 3, event.SchemaVersion)
+
+	mockDriver.On("Fetch", "group1", 10).Return([]*Event{event}, nil)
+
+	store := newStore(mockDriver).WithSchemaRegistry(registry).WithUpcasters(orderCreatedUpcasters())
+	events, err := store.driver.Fetch("group1", 10)
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total":10,"currency":"USD"}`, string(events[0].Data))
+	assert.Equal(t, 3, events[0].SchemaVersion)
+}
@@ -0,0 +1,280 @@
+package dbevent
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	defaultWebhookTimeoutMs   = 10000
+	defaultWebhookMaxAttempts = 5
+)
+
+const (
+	// WebhookSignatureHeader is the header carrying the HMAC-SHA256 signature of the body
+	WebhookSignatureHeader = "X-Event-Signature"
+	// WebhookEventTypeHeader is the header carrying the event type
+	WebhookEventTypeHeader = "X-Event-Type"
+	// WebhookEventIDHeader is the header carrying the event id
+	WebhookEventIDHeader = "X-Event-ID"
+	// WebhookAnyEventType matches every event type in WebhookConfig.Endpoints
+	WebhookAnyEventType = "*"
+)
+
+// WebhookEndpoint represents webhook destination
+type WebhookEndpoint struct {
+	URL       string
+	Secret    string
+	TimeoutMs int
+}
+
+// WebhookConfig represents webhook dispatcher configuration
+type WebhookConfig struct {
+	// Endpoints by event type. Use WebhookAnyEventType to receive every event.
+	Endpoints   map[string][]*WebhookEndpoint
+	MaxAttempts int
+	Backoff     *BackOffConfig
+	Client      *http.Client
+}
+
+// Delivery represents a webhook delivery attempt
+type Delivery struct {
+	EventID    uint
+	URL        string
+	Attempt    int
+	StatusCode int
+	Success    bool
+	Error      string
+	CreatedAt  *time.Time
+}
+
+// DeliveryRecorder represents webhook delivery attempt storage
+type DeliveryRecorder interface {
+	RecordDelivery(delivery *Delivery) error
+}
+
+// WebhookDispatcher delivers consumed events to http endpoints
+type WebhookDispatcher struct {
+	consumer *Consumer
+	recorder DeliveryRecorder
+	config   *WebhookConfig
+}
+
+// webhookPayload represents json body sent to endpoints. Data of content type other than JSON
+// is sent as base64 string.
+type webhookPayload struct {
+	ID            uint            `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	ContentType   string          `json:"content_type"`
+	Data          json.RawMessage `json:"data"`
+	CreatedAt     *time.Time      `json:"created_at"`
+}
+
+// NewWebhookDispatcher creates new webhook dispatcher on top of consumer. Recorder may be nil
+// to not record delivery attempts.
+func NewWebhookDispatcher(consumer *Consumer, recorder DeliveryRecorder, config *WebhookConfig) *WebhookDispatcher {
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = defaultWebhookMaxAttempts
+	}
+
+	if config.Backoff == nil {
+		config.Backoff = &BackOffConfig{}
+	}
+
+	if config.Client == nil {
+		config.Client = &http.Client{}
+	}
+
+	for _, endpoints := range config.Endpoints {
+		for _, endpoint := range endpoints {
+			if endpoint.TimeoutMs == 0 {
+				endpoint.TimeoutMs = defaultWebhookTimeoutMs
+			}
+		}
+	}
+
+	return &WebhookDispatcher{
+		consumer: consumer,
+		recorder: recorder,
+		config:   config,
+	}
+}
+
+// SignWebhookPayload returns signature of the body using secret
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start consumes events and delivers them to endpoints
+func (dispatcher *WebhookDispatcher) Start() {
+	dispatcher.consumer.Consume(dispatcher.Dispatch)
+}
+
+// Close dispatcher
+func (dispatcher *WebhookDispatcher) Close() {
+	dispatcher.consumer.Close()
+}
+
+// Dispatch delivers event to every endpoint configured for its type
+func (dispatcher *WebhookDispatcher) Dispatch(event *Event) error {
+	endpoints := dispatcher.endpoints(event.Type)
+
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	body, err := webhookBody(event)
+
+	if err != nil {
+		// the same event never marshals so retrying is pointless
+		return Permanent(err)
+	}
+
+	for _, endpoint := range endpoints {
+		if err := dispatcher.deliver(endpoint, event, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func webhookBody(event *Event) ([]byte, error) {
+	payload := &webhookPayload{
+		ID:            event.ID,
+		Type:          event.Type,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		ContentType:   event.ContentType,
+		Data:          json.RawMessage(event.Data),
+		CreatedAt:     event.CreatedAt,
+	}
+
+	if payload.ContentType == "" {
+		payload.ContentType = ContentTypeJSON
+	}
+
+	if payload.ContentType != ContentTypeJSON && event.Data != nil {
+		data, err := json.Marshal([]byte(event.Data))
+
+		if err != nil {
+			return nil, err
+		}
+
+		payload.Data = data
+	}
+
+	return json.Marshal(payload)
+}
+
+func (dispatcher *WebhookDispatcher) endpoints(eventType string) []*WebhookEndpoint {
+	var endpoints []*WebhookEndpoint
+
+	endpoints = append(endpoints, dispatcher.config.Endpoints[eventType]...)
+
+	if eventType != WebhookAnyEventType {
+		endpoints = append(endpoints, dispatcher.config.Endpoints[WebhookAnyEventType]...)
+	}
+
+	return endpoints
+}
+
+// deliver posts body to endpoint with retry. It gives up after max attempts or permanent
+// error and only returns error when the attempt cannot be recorded.
+func (dispatcher *WebhookDispatcher) deliver(endpoint *WebhookEndpoint, event *Event, body []byte) error {
+	backoff := NewBackoff(dispatcher.config.Backoff)
+
+	for attempt := 1; attempt <= dispatcher.config.MaxAttempts; attempt++ {
+		statusCode, err := dispatcher.post(endpoint, event, body)
+
+		now := time.Now()
+		delivery := &Delivery{
+			EventID:    event.ID,
+			URL:        endpoint.URL,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			Success:    err == nil,
+			CreatedAt:  &now,
+		}
+
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+
+		if dispatcher.recorder != nil {
+			if recordErr := dispatcher.recorder.RecordDelivery(delivery); recordErr != nil {
+				return recordErr
+			}
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		if IsPermanent(err) || backoff.Exhausted() || attempt == dispatcher.config.MaxAttempts {
+			log.Printf("giving up delivering event %d to %s after %d attempts. error: %s", event.ID, endpoint.URL, attempt, err)
+			return nil
+		}
+
+		backoff.SleepBackoff()
+	}
+
+	return nil
+}
+
+func (dispatcher *WebhookDispatcher) post(endpoint *WebhookEndpoint, event *Event, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(endpoint.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventTypeHeader, event.Type)
+	req.Header.Set(WebhookEventIDHeader, fmt.Sprint(event.ID))
+
+	if endpoint.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(endpoint.Secret, body))
+	}
+
+	resp, err := dispatcher.config.Client.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+
+		// client errors will not succeed on retry except timeout and rate limit
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return resp.StatusCode, Permanent(err)
+		}
+
+		return resp.StatusCode, err
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,205 @@
+package dbevent
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestWebhookDispatcher(recorder DeliveryRecorder, endpoints map[string][]*WebhookEndpoint) *WebhookDispatcher {
+	return NewWebhookDispatcher(nil, recorder, &WebhookConfig{
+		Endpoints:   endpoints,
+		MaxAttempts: 3,
+		Backoff: &BackOffConfig{
+			InitialBackoffMs:    1,
+			BackoffRandomFactor: -1,
+		},
+	})
+}
+
+func TestWebhookDispatcher_DispatchSigned(t *testing.T) {
+	var gotBody []byte
+	var gotSignature string
+	var gotType string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = ioutil.ReadAll(r.Body)
+		gotSignature = r.Header.Get(WebhookSignatureHeader)
+		gotType = r.Header.Get(WebhookEventTypeHeader)
+	}))
+	defer server.Close()
+
+	mockRecorder := &MockDeliveryRecorder{}
+	mockRecorder.On("RecordDelivery", mock.Anything).Return(nil)
+
+	dispatcher := newTestWebhookDispatcher(mockRecorder, map[string][]*WebhookEndpoint{
+		"testtype": {{URL: server.URL, Secret: "secret"}},
+	})
+
+	err := dispatcher.Dispatch(&Event{ID: 1, Type: "testtype", Data: JSON(`{"ID":"test1"}`)})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "testtype", gotType)
+	assert.Equal(t, SignWebhookPayload("secret", gotBody), gotSignature)
+
+	var payload map[string]interface{}
+	if assert.NoError(t, json.Unmarshal(gotBody, &payload)) {
+		assert.Equal(t, map[string]interface{}{"ID": "test1"}, payload["data"])
+	}
+
+	mockRecorder.AssertNumberOfCalls(t, "RecordDelivery", 1)
+	delivery := mockRecorder.Calls[0].Arguments.Get(0).(*Delivery)
+	assert.Equal(t, true, delivery.Success)
+	assert.Equal(t, http.StatusOK, delivery.StatusCode)
+}
+
+func TestWebhookDispatcher_DispatchRetry(t *testing.T) {
+	var called int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+
+		if called == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	mockRecorder := &MockDeliveryRecorder{}
+	mockRecorder.On("RecordDelivery", mock.Anything).Return(nil)
+
+	dispatcher := newTestWebhookDispatcher(mockRecorder, map[string][]*WebhookEndpoint{
+		WebhookAnyEventType: {{URL: server.URL}},
+	})
+
+	err := dispatcher.Dispatch(&Event{ID: 1, Type: "testtype"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, called)
+
+	mockRecorder.AssertNumberOfCalls(t, "RecordDelivery", 2)
+	assert.Equal(t, false, mockRecorder.Calls[0].Arguments.Get(0).(*Delivery).Success)
+	assert.Equal(t, true, mockRecorder.Calls[1].Arguments.Get(0).(*Delivery).Success)
+}
+
+func TestWebhookDispatcher_DispatchMaxAttempts(t *testing.T) {
+	var called int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	mockRecorder := &MockDeliveryRecorder{}
+	mockRecorder.On("RecordDelivery", mock.Anything).Return(nil)
+
+	dispatcher := newTestWebhookDispatcher(mockRecorder, map[string][]*WebhookEndpoint{
+		"testtype": {{URL: server.URL}},
+	})
+
+	err := dispatcher.Dispatch(&Event{ID: 1, Type: "testtype"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, called)
+	mockRecorder.AssertNumberOfCalls(t, "RecordDelivery", 3)
+}
+
+func TestWebhookDispatcher_DispatchNoEndpoint(t *testing.T) {
+	mockRecorder := &MockDeliveryRecorder{}
+
+	dispatcher := newTestWebhookDispatcher(mockRecorder, map[string][]*WebhookEndpoint{
+		"othertype": {{URL: "http://127.0.0.1:0"}},
+	})
+
+	err := dispatcher.Dispatch(&Event{ID: 1, Type: "testtype"})
+
+	assert.NoError(t, err)
+	mockRecorder.AssertNumberOfCalls(t, "RecordDelivery", 0)
+}
+
+func TestWebhookDispatcher_DispatchClientError(t *testing.T) {
+	var called int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	mockRecorder := &MockDeliveryRecorder{}
+	mockRecorder.On("RecordDelivery", mock.Anything).Return(nil)
+
+	dispatcher := newTestWebhookDispatcher(mockRecorder, map[string][]*WebhookEndpoint{
+		"testtype": {{URL: server.URL}},
+	})
+
+	err := dispatcher.Dispatch(&Event{ID: 1, Type: "testtype"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, called)
+}
+
+func TestWebhookDispatcher_DispatchProtobuf(t *testing.T) {
+	var gotBody []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	mockRecorder := &MockDeliveryRecorder{}
+	mockRecorder.On("RecordDelivery", mock.Anything).Return(nil)
+
+	dispatcher := newTestWebhookDispatcher(mockRecorder, map[string][]*WebhookEndpoint{
+		"testtype": {{URL: server.URL}},
+	})
+
+	event, _ := NewBuilder("testtype").Codec(ProtobufCodec{}).Data(&wrappers.StringValue{Value: "test1"}).Build()
+
+	err := dispatcher.Dispatch(event)
+
+	assert.NoError(t, err)
+
+	var payload struct {
+		ContentType string `json:"content_type"`
+		Data        string `json:"data"`
+	}
+	if assert.NoError(t, json.Unmarshal(gotBody, &payload)) {
+		assert.Equal(t, ContentTypeProtobuf, payload.ContentType)
+
+		data, err := base64.StdEncoding.DecodeString(payload.Data)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(event.Data), data)
+	}
+}
+
+func TestWebhookDispatcher_DispatchInvalidJSON(t *testing.T) {
+	dispatcher := newTestWebhookDispatcher(&MockDeliveryRecorder{}, map[string][]*WebhookEndpoint{
+		"testtype": {{URL: "http://localhost"}},
+	})
+
+	err := dispatcher.Dispatch(&Event{ID: 1, Type: "testtype", Data: JSON(`{invalid`)})
+
+	assert.True(t, IsPermanent(err))
+}
+
+func TestStore_NewWebhookDispatcher(t *testing.T) {
+	dispatcher := newStore(new(MockStoreDriver)).NewWebhookDispatcher("group1", &ConsumerConfig{}, &WebhookConfig{})
+
+	assert.Nil(t, dispatcher.recorder)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dispatcher.config.Endpoints = map[string][]*WebhookEndpoint{"testtype": {{URL: server.URL, TimeoutMs: 1000}}}
+
+	assert.NoError(t, dispatcher.Dispatch(&Event{ID: 1, Type: "testtype", Data: JSON(`{}`)}))
+}